@@ -0,0 +1,212 @@
+package main
+
+import (
+	"distributed_system/internal/agent/api"
+	"distributed_system/internal/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// localAPI calls the API of the agent running on this host
+type localAPI struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// localAPIFor fails when the agent API is disabled
+func localAPIFor(cfg *config.ConfigAgents) (*localAPI, error) {
+	if cfg.API.Listen == "" {
+		return nil, fmt.Errorf("the agent API is disabled, set api.listen")
+	}
+	return newLocalAPI(cfg.API.Listen), nil
+}
+
+// newLocalAPI reaches an API listening on all interfaces through loopback,
+// the only address its status endpoints answer
+func newLocalAPI(listen string) *localAPI {
+	host, port, err := net.SplitHostPort(listen)
+	if err == nil && (host == "" || net.ParseIP(host).IsUnspecified()) {
+		listen = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	return &localAPI{
+		baseURL: "http://" + listen,
+		// a sync waits for the controller, including retries
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (c *localAPI) status() (*api.Status, error) {
+	var status api.Status
+	if err := c.do(http.MethodGet, "/status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *localAPI) sync(namespace string) ([]api.NamespaceStatus, error) {
+	path := "/sync"
+	if namespace != "" {
+		path += "?namespace=" + url.QueryEscape(namespace)
+	}
+
+	var namespaces []api.NamespaceStatus
+	if err := c.do(http.MethodPost, path, &namespaces); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// do decodes the data field of a response.Success body
+func (c *localAPI) do(method, path string, output interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("agent API not reachable, is the agent running? %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Data  interface{} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	response.Data = output
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("unexpected response (%d): %s", resp.StatusCode, string(body))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if response.Error != nil {
+			return fmt.Errorf("%s (%d)", response.Error.Message, resp.StatusCode)
+		}
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func statusCommand(args []string) error {
+	flags := newFlagSet("status")
+	asJSON := flags.Bool("json", false, "print the raw status")
+	flags.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := localAPIFor(cfg)
+	if err != nil {
+		return err
+	}
+
+	status, err := client.status()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+
+	printStatus(status)
+	return nil
+}
+
+func syncCommand(args []string) error {
+	flags := newFlagSet("sync")
+	namespace := flags.String("namespace", "", "only sync this namespace")
+	flags.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	client, err := localAPIFor(cfg)
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := client.sync(*namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, ns := range namespaces {
+		if *namespace == "" || ns.Namespace == *namespace {
+			fmt.Printf("%s: version %d queued for %d worker(s)\n", ns.Namespace, ns.Version, ns.Workers)
+		}
+	}
+	return nil
+}
+
+func printStatus(status *api.Status) {
+	fmt.Printf("Agent:      %s (version %s, up %s)\n", status.AgentID, status.AgentVersion, time.Since(status.StartedAt).Round(time.Second))
+
+	controller := "unreachable"
+	if status.Controller.Reachable {
+		controller = "reachable"
+	}
+	if status.Controller.LastFetchAt == nil {
+		controller = "not contacted yet"
+	}
+	fmt.Printf("Controller: %s, %s\n", status.Controller.URL, controller)
+	if status.Controller.LastSuccessAt != nil {
+		fmt.Printf("            last successful fetch %s\n", formatTime(status.Controller.LastSuccessAt))
+	}
+	if status.Controller.LastError != "" {
+		fmt.Printf("            last error: %s\n", status.Controller.LastError)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Println()
+	fmt.Fprintln(w, "NAMESPACE\tVERSION\tWORKERS\tLAST FETCH\tERROR")
+	for _, ns := range status.Namespaces {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", ns.Namespace, ns.Version, ns.Workers, formatTime(ns.LastFetchAt), ns.LastError)
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Fprintln(w, "WORKER\tNAMESPACE\tSTATE\tAPPLIED\tPENDING\tATTEMPTS\tERROR")
+	for _, d := range status.Deliveries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", d.Worker, d.Namespace, d.State, d.AppliedVersion, d.PendingVersion, d.Attempts, d.LastError)
+	}
+	w.Flush()
+
+	if len(status.Discovered) > 0 {
+		fmt.Println()
+		fmt.Fprintln(w, "DISCOVERED\tSOURCE\tLAST HEALTHY\tFAILURES")
+		for _, d := range status.Discovered {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", d.URL, d.Source, formatTime(d.LastHealthyAt), d.Failures)
+		}
+		w.Flush()
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
@@ -1,278 +1,179 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"distributed_system/internal/agent"
 	"distributed_system/internal/config"
-	domainConfig "distributed_system/internal/domain/config"
-	"distributed_system/pkg/utils"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
-	"sync"
+	"strings"
 	"syscall"
-	"time"
 )
 
-var (
-	version int = 0
-	countFetch int = 0
-	RWMutex sync.RWMutex
-)
+// agentVersion is overridden at build time with -ldflags "-X main.agentVersion=..."
+var agentVersion = "dev"
 
-func main() {
-	// Get config path from env or use default
-	configPath := os.Getenv("CONFIG_PATH")
-	if configPath == "" {
-		configPath = "config"
-	}
+const usage = `Usage: agent [command] [flags]
 
-	agentsCfg, err := config.LoadConfigAgents(configPath)
-	if err != nil {
-		log.Fatalf("Failed to load agents config: %v", err)
+Commands:
+  run                      Run the agent (default)
+  register [--token T]     Enroll with a registration token (default identity.internal_key)
+  status [--json]          Show what the running agent is doing
+  sync [--namespace NS]    Make the running agent fetch its config and push it now
+  reset [--token T]        Wipe the local state and enroll again
+  version                  Print the agent version
+
+The config is read from $CONFIG_PATH (default "config").
+`
+
+func main() {
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "run":
+		err = runCommand(args)
+	case "register":
+		err = registerCommand(args)
+	case "status":
+		err = statusCommand(args)
+	case "sync":
+		err = syncCommand(args)
+	case "reset":
+		err = resetCommand(args)
+	case "version":
+		fmt.Println(agentVersion)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
 	}
 
-	// Self registration
-	credential, err := selfRegistration(agentsCfg)
 	if err != nil {
-		log.Fatalf("[Agent] Failed to read internal key: %v", err)
+		log.Fatalf("[Agent] %v", err)
 	}
+}
 
-	log.Println("============================================================")
-	log.Println("[Agent] Starting...")
-	log.Printf("[Agent] Controller URL: %s", agentsCfg.Controller.URL)
-	log.Printf("[Agent] Worker URL: %s", agentsCfg.Worker.URL)
-	log.Println("============================================================")
+func runCommand(args []string) error {
+	parseFlags("run", args)
 
-	log.Println("[Agent] Fetching initial config from Controller...")
-	initialConfig, err := fetchConfigFromController(agentsCfg, credential)
+	a, err := newAgent()
 	if err != nil {
-		log.Fatalf("[Agent] Failed to fetch initial config: %v", err)
+		return err
 	}
+	defer a.Close()
 
-	log.Printf("[Agent] Initial config: Version=%d, URL=%s", initialConfig.Version, initialConfig.ConfigURL)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Println("[Agent] Pushing initial config to Worker...")
-	if err := pushConfigToWorker(agentsCfg, initialConfig); err != nil {
-		log.Printf("[Agent] Warning: Failed to push to Worker: %v", err)
-	} else {
-		log.Println("[Agent] Successfully pushed initial config to Worker!")
+	if err := a.Run(ctx); err != nil {
+		return err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	poolingInterval := time.Duration(initialConfig.PoolingInterval) * time.Second
-
-	go startPolling(ctx, agentsCfg, initialConfig, credential, poolingInterval)
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-
-	<-sigCh
-	log.Println("[Agent] Shutting down...")
-	cancel()
-
 	log.Println("[Agent] Stopped.")
+	return nil
 }
 
-func startPolling(ctx context.Context, agentsCfg *config.ConfigAgents, lastConfig *domainConfig.Config, credential string, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	log.Printf("[Agent] Started polling every %v", interval)
-
-	for {
-		select {
-		case <-ticker.C:
-			newConfig, err := fetchConfigFromController(agentsCfg, credential)
-			if err != nil {
-				log.Printf("[Agent] Error fetching config: %v", err)
-				continue
-			}
-
-			RWMutex.Lock()
-			countFetch += 1
-
-			if newConfig != lastConfig || countFetch > 3{
-				countFetch = 0
-				log.Printf("[Agent] Config changed! Version: %d -> %d", lastConfig.Version, newConfig.Version)
-
-				if err := pushConfigToWorker(agentsCfg, newConfig); err != nil {
-					log.Printf("[Agent] Error pushing to Worker: %v", err)
-				} else {
-					log.Printf("[Agent] Successfully pushed updated config (version %d) to Worker!", newConfig.Version)
-				}
-
-				lastConfig = newConfig
-				newInterval := time.Duration(newConfig.PoolingInterval) * time.Second
-				if newInterval != interval {
-					log.Printf("[Agent] Pooling interval changed: %v -> %v", interval, newInterval)
-					ticker.Reset(newInterval)
-					interval = newInterval
-				}
-			} else {
-				log.Printf("[Agent] Config unchanged (version %d)", newConfig.Version)
-			}
-			RWMutex.Unlock()
-		case <-ctx.Done():
-			log.Println("[Agent] Polling stopped")
-			return
-		}
-	}
-}
-
-func fetchConfigFromController(cfg *config.ConfigAgents, credential string) (*domainConfig.Config, error) {
-	url := fmt.Sprintf("%s/config/agent", cfg.Controller.URL)
+func registerCommand(args []string) error {
+	flags := newFlagSet("register")
+	token := flags.String("token", "", "registration token (default identity.internal_key)")
+	flags.Parse(args)
 
-	req, err := http.NewRequest("GET", url, nil)
+	cfg, err := loadConfig()
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+credential)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	a, err := agent.New(cfg, agentVersion)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
-	}
-	defer resp.Body.Close()
-
-
-	for resp.StatusCode != http.StatusOK {
-		fmt.Println("[Agent] Got non-200 status code from Controller, trying again...")
-		time.Sleep(30 * time.Second)
-		resp, err = client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("error sending request: %w", err)
-		}
-		defer resp.Body.Close()
+		return err
 	}
+	defer a.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response: %w", err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	var response struct {
-		Status string    `json:"status"`
-		Data domainConfig.Config `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
-	}
-
-	RWMutex.Lock()
-	if version == 0 || response.Data.Version > version {
-		utils.WriteJson("config", &domainConfig.Config{
-			Version: response.Data.Version,
-			ConfigURL: response.Data.ConfigURL,
-			PoolingInterval: response.Data.PoolingInterval,
-			UUID: response.Data.UUID,
-			CreatedAt: response.Data.CreatedAt,
-		})	
-	}
-	RWMutex.Unlock()
-
-	return &response.Data, nil
+	return a.Register(ctx, tokenOrDefault(*token, cfg))
 }
 
-func selfRegistration(cfg *config.ConfigAgents) (string, error) {
-	type Credential struct {
-		CredentialKey string `json:"credential_key"`
-	}
-
-	// check if already registered
-	credential, _ := utils.ReadJSON[Credential]("credential")
-
-	if credential != nil && credential.CredentialKey != "" {
-		return credential.CredentialKey, nil
-	}
+func resetCommand(args []string) error {
+	flags := newFlagSet("reset")
+	token := flags.String("token", "", "registration token (default identity.internal_key)")
+	flags.Parse(args)
 
-	url := fmt.Sprintf("%s/agent/register", cfg.Controller.URL)
-
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return "",fmt.Errorf("error creating request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.Identity.InternalKey)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	cfg, err := loadConfig()
 	if err != nil {
-		return "", fmt.Errorf("error sending request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
 
+	// fails while an agent runs on the same state directory, it would keep
+	// using the old credential and write it back
+	a, err := agent.New(cfg, agentVersion)
 	if err != nil {
-		return "", fmt.Errorf("error reading response: %w", err)
+		return err
 	}
+	defer a.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	if err := a.Reset(); err != nil {
+		return err
 	}
+	log.Println("[Agent] Local state removed")
 
-	var response struct {
-		Status string `json:"status"`
-		Data   string `json:"data"`
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := json.Unmarshal(body, &response); err != nil {
-		return "", fmt.Errorf("error decoding response: %w", err)
+	if err := a.Register(ctx, tokenOrDefault(*token, cfg)); err != nil {
+		return fmt.Errorf("state was reset but enrollment failed, retry with `agent register`: %w", err)
 	}
-
-	utils.WriteJson("credential", Credential{
-		CredentialKey: response.Data,
-	})
-
-	return response.Data, nil
+	return nil
 }
 
-func pushConfigToWorker(cfg *config.ConfigAgents, config *domainConfig.Config) error {
-	workerConfig := map[string]interface{}{
-		"config_url":       config.ConfigURL,
-		"pooling_interval": config.PoolingInterval,
-		"version":          config.Version,
-		"uuid":             config.UUID,
+func newAgent() (*agent.Agent, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
 	}
+	return agent.New(cfg, agentVersion)
+}
 
-	jsonData, err := json.Marshal(workerConfig)
-	if err != nil {
-		return fmt.Errorf("error marshaling config: %w", err)
+func loadConfig() (*config.ConfigAgents, error) {
+	// Get config path from env or use default
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		configPath = "config"
 	}
 
-	url := fmt.Sprintf("%s/config", cfg.Worker.URL)
-	fmt.Println(url)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	agentsCfg, err := config.LoadConfigAgents(configPath)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("failed to load agents config: %w", err)
 	}
+	return agentsCfg, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.Worker.InternalKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
+func tokenOrDefault(token string, cfg *config.ConfigAgents) string {
+	if token != "" {
+		return token
 	}
-	defer resp.Body.Close()
+	return cfg.Identity.InternalKey
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+func newFlagSet(command string) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: agent %s [flags]\n", command)
+		flags.PrintDefaults()
 	}
+	return flags
+}
 
-	return nil
-}
\ No newline at end of file
+// parseFlags rejects flags on commands that take none
+func parseFlags(command string, args []string) {
+	newFlagSet(command).Parse(args)
+}
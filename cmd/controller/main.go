@@ -1,19 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"distributed_system/internal/config"
 	"distributed_system/internal/delivery/http/handler"
 	"distributed_system/internal/delivery/http/middleware"
+	domainAdmin "distributed_system/internal/domain/admin"
 	"distributed_system/internal/infrastructure/cache"
 	"distributed_system/internal/infrastructure/database"
 	"distributed_system/internal/infrastructure/redis"
 	"distributed_system/internal/repository/admin"
 	"distributed_system/internal/repository/agents"
 	configRepo "distributed_system/internal/repository/config"
+	webhookRepo "distributed_system/internal/repository/webhook"
 	adminUC "distributed_system/internal/usecase/admin"
 	agentUC "distributed_system/internal/usecase/agents"
 	configUC "distributed_system/internal/usecase/config"
+	webhookUC "distributed_system/internal/usecase/webhook"
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/pki"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
@@ -42,24 +50,44 @@ func main() {
 
 	redisClient := initRedis(cfg)
 
+	ca := initCA(cfg)
+	configSigner := initConfigSigner(cfg)
+
 	configCache := cache.NewConfigCache(redisClient)
+	sessionCache := cache.NewSessionCache(redisClient)
+	loginAttemptCache := cache.NewLoginAttemptCache(redisClient)
 	configRepository := configRepo.NewCOnfigRepository(db.DB, configCache)
 	agentsRepository := agents.NewAgentRepository(db.DB)
+	registrationTokenRepository := agents.NewRegistrationTokenRepository(db.DB)
 	adminRepository := admin.NewAdminRepository(db.DB)
+	loginLockoutRepository := admin.NewLoginLockoutRepository(db.DB)
+	apiKeyRepository := admin.NewAPIKeyRepository(db.DB)
+	webhookRepository := webhookRepo.NewWebhookRepository(db.DB)
+	webhookDeliveryRepository := webhookRepo.NewDeliveryRepository(db.DB)
+
+	webhookUsecase := webhookUC.NewWebhookUsecase(webhookRepository, webhookDeliveryRepository, cfg)
+	configUsecase := configUC.NewConfigUsecase(configRepository, agentsRepository, cfg, configCache, configSigner, webhookUsecase)
+	agentsUsecase := agentUC.NewAgentUsecase(agentsRepository, registrationTokenRepository, cfg, ca, configSigner, webhookUsecase)
+	adminUsecase := adminUC.NewAdminUsecase(adminRepository, loginLockoutRepository, apiKeyRepository, cfg, sessionCache, loginAttemptCache)
 
-	configUsecase := configUC.NewConfigUsecase(configRepository, agentsRepository, cfg, configCache)
-	agentsUsecase := agentUC.NewAgentUsecase(agentsRepository, cfg)
-	adminUsecase := adminUC.NewAdminUsecase(adminRepository, cfg)
+	if err := configUsecase.SignUnsignedConfigs(context.Background()); err != nil {
+		fmt.Printf("Failed to sign stored configs: %v\n", err)
+		os.Exit(1)
+	}
 
 	configHandler := handler.NewConfigHandler(configUsecase)
 	agentHandler := handler.NewAgentsHandler(agentsUsecase)
 	adminHandler := handler.NewAdminHandler(adminUsecase)
+	webhookHandler := handler.NewWebhookHandler(webhookUsecase)
+
+	go webhookUsecase.Run(context.Background())
+	go agentsUsecase.MonitorOffline(context.Background())
 
 	r.Use(gin.Recovery())
 	r.Use(gin.Logger())
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -67,41 +95,188 @@ func main() {
 	}))
 
 	r.POST("/login", adminHandler.Login)
+	r.POST("/login/2fa", adminHandler.LoginMFA)
+	r.GET("/login/oidc", adminHandler.OIDCLogin)
+	r.GET("/login/oidc/callback", adminHandler.OIDCCallback)
+	r.POST("/refresh", adminHandler.Refresh)
+	r.POST("/logout", middleware.AdminPasswordChangeValidation(cfg, adminUsecase), adminHandler.Logout)
+
+	groupAdmin := r.Group("/admin")
+	{
+		// reachable while a forced password change is pending
+		me := groupAdmin.Group("/me")
+		{
+			me.Use(middleware.AdminPasswordChangeValidation(cfg, adminUsecase))
+			me.GET("", adminHandler.Me)
+			me.PUT("/password", adminHandler.ChangePassword)
+			me.POST("/2fa/enroll", adminHandler.EnrollTOTP)
+			me.POST("/2fa/confirm", adminHandler.ConfirmTOTP)
+			me.POST("/2fa/disable", adminHandler.DisableTOTP)
+			me.POST("/2fa/recovery-codes", adminHandler.RegenerateRecoveryCodes)
+		}
+
+		users := groupAdmin.Group("/users")
+		{
+			users.Use(middleware.AdminValidation(cfg, adminUsecase))
+			users.Use(middleware.RequirePermission(domainAdmin.PermManageAdmins))
+			users.POST("", adminHandler.Create)
+			users.GET("", adminHandler.GetAll)
+			users.GET("/:uuid", adminHandler.GetById)
+			users.POST("/:uuid/disable", adminHandler.Disable)
+			users.POST("/:uuid/enable", adminHandler.Enable)
+			users.PUT("/:uuid/role", adminHandler.UpdateRole)
+			users.DELETE("/:uuid", adminHandler.Delete)
+		}
+
+		lockouts := groupAdmin.Group("/lockouts")
+		{
+			lockouts.Use(middleware.AdminValidation(cfg, adminUsecase))
+			lockouts.Use(middleware.RequirePermission(domainAdmin.PermManageAdmins))
+			lockouts.GET("", adminHandler.GetLockouts)
+		}
+
+		apiKeys := groupAdmin.Group("/api-keys")
+		{
+			apiKeys.Use(middleware.AdminValidation(cfg, adminUsecase))
+			apiKeys.Use(middleware.RequirePermission(domainAdmin.PermManageAdmins))
+			apiKeys.POST("", adminHandler.CreateAPIKey)
+			apiKeys.GET("", adminHandler.GetAPIKeys)
+			apiKeys.DELETE("/:uuid", adminHandler.RevokeAPIKey)
+		}
+	}
 
 	groupConfig := r.Group("/config")
 	{
 		admin := groupConfig.Group("/admin")
 		{
-			admin.Use(middleware.AdminValidation(cfg))
-			admin.GET("", configHandler.GetLatestConfigAdmin)
-			admin.PUT("", configHandler.Update)
-			admin.POST("", configHandler.Create)
+			admin.Use(middleware.AdminOrAPIKeyValidation(cfg, adminUsecase))
+			admin.GET("", middleware.RequirePermission(domainAdmin.PermReadConfigs), configHandler.GetLatestConfigAdmin)
+			admin.PUT("", middleware.RequirePermission(domainAdmin.PermWriteConfigs), configHandler.Update)
+			admin.POST("", middleware.RequirePermission(domainAdmin.PermWriteConfigs), configHandler.Create)
+			admin.POST("/rollback", middleware.RequirePermission(domainAdmin.PermWriteConfigs), configHandler.Rollback)
 		}
 
 		agent := groupConfig.Group("/agent") 
 		{
-			agent.Use(middleware.InternalGetConfigVaidation(cfg))
+			agent.Use(middleware.InternalGetConfigVaidation(agentsUsecase))
+			agent.Use(middleware.AgentCertificateValidation(cfg))
+			agent.Use(middleware.AgentNamespaceValidation())
 			agent.GET("", configHandler.GetLatestConfigModel)
 		}
 
 	}
 
+	groupWebhooks := r.Group("/webhooks")
+	{
+		groupWebhooks.Use(middleware.AdminValidation(cfg, adminUsecase))
+		groupWebhooks.Use(middleware.RequirePermission(domainAdmin.PermManageWebhooks))
+		groupWebhooks.POST("", webhookHandler.Create)
+		groupWebhooks.GET("", webhookHandler.GetAll)
+		groupWebhooks.GET("/:uuid", webhookHandler.GetById)
+		groupWebhooks.PUT("/:uuid", webhookHandler.Update)
+		groupWebhooks.DELETE("/:uuid", webhookHandler.Delete)
+		groupWebhooks.POST("/:uuid/ping", webhookHandler.Ping)
+		groupWebhooks.GET("/:uuid/deliveries", webhookHandler.GetDeliveries)
+		groupWebhooks.POST("/:uuid/deliveries/:delivery/redeliver", webhookHandler.Redeliver)
+	}
+
 	groupAgent := r.Group("/agent")
 	{
 		register := groupAgent.Group("/register")
 		{
-			register.Use(middleware.ValidationRegistrationAgent(cfg))
+			register.Use(middleware.ValidationRegistrationAgent(agentsUsecase))
 			register.POST("", agentHandler.Register)
 		}
 
+		credential := groupAgent.Group("/credential")
+		{
+			credential.Use(middleware.InternalGetConfigVaidation(agentsUsecase))
+			credential.Use(middleware.AgentCertificateValidation(cfg))
+			credential.POST("/rotate", agentHandler.RotateCredential)
+		}
+
+		// renewal only needs the credential so an expired certificate can be replaced
+		certificate := groupAgent.Group("/certificate")
+		{
+			certificate.Use(middleware.InternalGetConfigVaidation(agentsUsecase))
+			certificate.POST("/renew", agentHandler.RenewCertificate)
+		}
+
 		admin := groupAgent.Group("/admin")
 		{
-			admin.Use(middleware.AdminValidation(cfg))
-			admin.GET("", agentHandler.GenerateRegistrationConfifg)
+			admin.Use(middleware.AdminOrAPIKeyValidation(cfg, adminUsecase))
+			admin.Use(middleware.RequirePermission(domainAdmin.PermManageAgents))
+			admin.POST("/tokens", agentHandler.CreateRegistrationToken)
+			admin.GET("/tokens", agentHandler.GetRegistrationTokens)
+			admin.DELETE("/tokens/:uuid", agentHandler.RevokeRegistrationToken)
+			admin.GET("/agents", agentHandler.GetAll)
+			admin.GET("/agents/:uuid", agentHandler.GetById)
+			admin.POST("/agents/:uuid/revoke", agentHandler.RevokeCredential)
 		}
 	}
 
-	r.Run(fmt.Sprintf(":%d", servicePort))
+	if ca == nil {
+		r.Run(fmt.Sprintf(":%d", servicePort))
+		return
+	}
+
+	srv := &http.Server{
+		Addr:      fmt.Sprintf(":%d", servicePort),
+		Handler:   r,
+		TLSConfig: initServerTLS(cfg, ca),
+	}
+
+	if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+		fmt.Printf("Controller server error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func initCA(cfg *config.Config) *pki.CA {
+	if !cfg.TLS.Enabled {
+		return nil
+	}
+
+	ca, err := pki.LoadOrCreateCA(cfg.TLS.CACertFile, cfg.TLS.CAKeyFile, "distributed-system-ca")
+	if err != nil {
+		fmt.Printf("Failed to load CA: %v\n", err)
+		os.Exit(1)
+	}
+	return ca
+}
+
+func initConfigSigner(cfg *config.Config) *crypto.Ed25519Signer {
+	signer, err := crypto.LoadOrCreateEd25519Signer(cfg.Security.ConfigSigningKeyFile)
+	if err != nil {
+		fmt.Printf("Failed to load config signing key: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Config signing key %s, public key: %s\n", signer.KeyID(), signer.PublicKey())
+	return signer
+}
+
+func initServerTLS(cfg *config.Config, ca *pki.CA) *tls.Config {
+	var cert tls.Certificate
+	var err error
+
+	if cfg.TLS.CertFile != "" {
+		cert, err = tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	} else {
+		cert, err = ca.IssueServerCertificate(cfg.TLS.Hosts, 365*24*time.Hour)
+	}
+	if err != nil {
+		fmt.Printf("Failed to load server certificate: %v\n", err)
+		os.Exit(1)
+	}
+
+	clientCAs, err := pki.CertPool(ca.CertPEM)
+	if err != nil {
+		fmt.Printf("Failed to load CA pool: %v\n", err)
+		os.Exit(1)
+	}
+
+	return pki.ServerTLSConfig(cert, clientCAs)
 }
 
 func initDatabase(cfg *config.Config) *database.Database {
@@ -6,7 +6,9 @@ import (
 	"distributed_system/internal/config"
 	"distributed_system/internal/delivery/http/handler"
 	"distributed_system/internal/delivery/http/middleware"
+	domainWorker "distributed_system/internal/domain/worker"
 	"distributed_system/internal/usecase/worker"
+	"distributed_system/pkg/pki"
 	"fmt"
 	"log"
 	"net/http"
@@ -39,13 +41,25 @@ func main() {
 
 	httpClient := &http.Client{
 		Timeout: 30 * time.Second,
-		// Allow insecure HTTPS for development (self-signed certificates)
+		// insecure_skip_verify allows self-signed task endpoints in development
 		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: workerCfg.TLS.InsecureSkipVerify},
 		},
 	}
 
-	workerUsecase := worker.NewWorkerUsecase(httpClient)
+	configVerifier, err := workerCfg.ConfigSigning.Verifier()
+	if err != nil {
+		log.Fatalf("Invalid config_signing.trusted_keys: %v", err)
+	}
+	if !configVerifier.Enabled() {
+		if workerCfg.ConfigSigning.AllowUnsigned {
+			log.Println("[Worker] Warning: config_signing.allow_unsigned is set, pushed configs are not verified")
+		} else {
+			log.Println("[Worker] No trusted config signing keys, pinning the keys the agent forwards with its first push")
+		}
+	}
+
+	workerUsecase := worker.NewWorkerUsecase(httpClient, configVerifier, workerCfg.ConfigSigning.AllowUnsigned)
 	workerHandler := handler.NewWorkerHandler(workerUsecase)
 
 	r := gin.Default()
@@ -64,8 +78,10 @@ func main() {
 	
 	privateGroup := r.Group("/private")
 	{
+		privateGroup.Use(middleware.WorkerCertificateValidation(workerCfg))
 		privateGroup.Use(middleware.ValidationAgentWorker(workerCfg))
 		privateGroup.POST("/config", workerHandler.UpdateConfig)
+		privateGroup.GET("/config/version", workerHandler.AppliedVersion)
 	}
 
 	r.GET("/health", func(c *gin.Context) {
@@ -80,19 +96,54 @@ func main() {
 		Handler: r,
 	}
 
+	if workerCfg.TLS.Enabled {
+		srv.TLSConfig = loadServerTLS(workerCfg)
+	}
+
 	go func() {
 		log.Printf("[Worker] Server started on port %d...", workerCfg.Server.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+
+		var err error
+		if workerCfg.TLS.Enabled {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Worker server error: %v", err)
 		}
 	}()
 
+	announceCtx, stopAnnouncing := context.WithCancel(context.Background())
+	announced := make(chan struct{})
+	if workerCfg.Discovery.Enabled() {
+		announcer := worker.NewAnnouncer(domainWorker.Announcement{
+			URL:         workerCfg.Discovery.AdvertiseURL + "/private",
+			HealthURL:   workerCfg.Discovery.AdvertiseURL + "/health",
+			InternalKey: workerCfg.Auth.InternalKey,
+			Namespaces:  workerCfg.Discovery.Namespaces,
+		}, workerCfg.Discovery.AgentURL, workerCfg.Discovery.Token, workerCfg.Discovery.Dir,
+			workerCfg.Discovery.Interval, &http.Client{Timeout: 10 * time.Second})
+
+		log.Printf("[Worker] Announcing %s to the agent", workerCfg.Discovery.AdvertiseURL)
+		go func() {
+			announcer.Run(announceCtx)
+			close(announced)
+		}()
+	} else {
+		close(announced)
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	<-quit
 	log.Println("[Worker] Shutting down server...")
 
+	// withdraw first, so the agent stops pushing before the server goes away
+	stopAnnouncing()
+	<-announced
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -102,3 +153,22 @@ func main() {
 
 	log.Println("[Worker] Server stopped.")
 }
+
+func loadServerTLS(workerCfg *config.WorkerConfig) *tls.Config {
+	cert, err := tls.LoadX509KeyPair(workerCfg.TLS.CertFile, workerCfg.TLS.KeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load worker certificate: %v", err)
+	}
+
+	caPEM, err := os.ReadFile(workerCfg.TLS.CAFile)
+	if err != nil {
+		log.Fatalf("Failed to read CA file: %v", err)
+	}
+
+	clientCAs, err := pki.CertPool(caPEM)
+	if err != nil {
+		log.Fatalf("Failed to load CA pool: %v", err)
+	}
+
+	return pki.ServerTLSConfig(cert, clientCAs)
+}
@@ -0,0 +1,216 @@
+// Package agent is the agent runtime: it enrolls with the controller, keeps
+// its workers on the latest signed config of every namespace they follow and
+// renews its own credentials. The
+// cmd/agents binary is a thin wrapper around it, so it can be embedded.
+package agent
+
+import (
+	"context"
+	"distributed_system/internal/agent/api"
+	"distributed_system/internal/agent/client"
+	agentConfig "distributed_system/internal/agent/config"
+	"distributed_system/internal/agent/discovery"
+	"distributed_system/internal/config"
+	domainAgents "distributed_system/internal/domain/agents"
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/retry"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	controllerTimeout = 30 * time.Second
+	workerTimeout     = 10 * time.Second
+)
+
+type Agent struct {
+	cfg *config.ConfigAgents
+	// version is reported to the controller at registration
+	version string
+
+	store *agentConfig.Store
+	tls   *client.TLS
+	// verifier checks configs against config_signing.trusted_keys, or the
+	// controller key pinned at enrollment when none are listed
+	verifier   *crypto.Ed25519Verifier
+	controller *client.ConfigClient
+	worker     *client.WorkerClient
+
+	credentialMu sync.RWMutex
+	credential   *domainAgents.Credential
+	// maintainMu serializes credential rotation and certificate renewal
+	maintainMu sync.Mutex
+
+	// workersMu guards the workers and namespaces, which change as workers
+	// are discovered and dropped
+	workersMu sync.Mutex
+	// ctx is the Run context, set once Run starts the workers
+	ctx context.Context
+	// errCh carries a failure that stops Run, e.g. a namespace failing for good
+	errCh   chan error
+	workers map[string]*workerTarget
+	// namespaces are synced independently, each while a worker follows it
+	namespaces map[string]*namespaceSync
+
+	discovery *discovery.Discovery
+
+	startedAt time.Time
+	// contactMu guards the outcome of the latest config fetch
+	contactMu sync.Mutex
+	contact   api.ControllerStatus
+}
+
+// New builds an agent from its configuration. Nothing is sent until Run.
+func New(cfg *config.ConfigAgents, version string) (*Agent, error) {
+	verifier, err := cfg.ConfigSigning.Verifier()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config_signing.trusted_keys: %w", err)
+	}
+
+	if len(cfg.WorkerTargets()) == 0 && !cfg.Discovery.Enabled {
+		return nil, fmt.Errorf("no workers configured and discovery is disabled")
+	}
+
+	store, err := agentConfig.OpenStore(cfg.State.Dir, cfg.State.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	tls, err := client.LoadTLS(cfg.TLS, store)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to load TLS material: %w", err)
+	}
+
+	a := &Agent{
+		cfg:        cfg,
+		version:    version,
+		store:      store,
+		tls:        tls,
+		verifier:   verifier,
+		controller: client.NewConfigClient(cfg.Controller.URL, tls.HTTPClient(controllerTimeout)),
+		worker:     client.NewWorkerClient(tls.HTTPClient(workerTimeout)),
+		startedAt:  time.Now(),
+		errCh:      make(chan error, 1),
+		workers:    map[string]*workerTarget{},
+		namespaces: map[string]*namespaceSync{},
+	}
+
+	if cfg.Discovery.Enabled {
+		a.discovery = discovery.NewDiscovery(cfg.Discovery, a, tls.HTTPClient(workerTimeout))
+	}
+
+	return a, nil
+}
+
+// Close releases the state directory; call it once the agent is done
+func (a *Agent) Close() error {
+	return a.store.Close()
+}
+
+// Run goes through the agent lifecycle: register (or load the stored
+// credential), bootstrap the workers with the current config of every
+// namespace, then keep them in sync until ctx is cancelled.
+func (a *Agent) Run(ctx context.Context) error {
+	if err := a.register(ctx); err != nil {
+		return fmt.Errorf("failed to register: %w", err)
+	}
+
+	if a.tls.NeedsCertificate() {
+		// with a certificate still in hand, try once and renew later instead
+		// of holding up the start
+		policy := a.cfg.Retry
+		if a.tls.HasCertificate() {
+			policy = retry.Policy{}
+		}
+
+		if err := a.renewCertificate(ctx, policy); err != nil {
+			if !a.tls.HasCertificate() {
+				return fmt.Errorf("failed to obtain client certificate: %w", err)
+			}
+			// the current certificate still works until it expires
+			log.Printf("[Agent] Warning: failed to renew client certificate: %v", err)
+		}
+	}
+
+	log.Println("============================================================")
+	log.Println("[Agent] Starting...")
+	log.Printf("[Agent] Controller URL: %s", a.cfg.Controller.URL)
+	for _, worker := range a.cfg.WorkerTargets() {
+		log.Printf("[Agent] Worker URL: %s (namespaces: %s)", worker.URL, strings.Join(worker.Namespaces, ", "))
+	}
+	if a.discovery != nil {
+		log.Printf("[Agent] Worker discovery: API %s, directory %q", a.cfg.API.Listen, a.cfg.Discovery.Dir)
+	}
+	log.Println("============================================================")
+
+	a.workersMu.Lock()
+	a.ctx = ctx
+	a.workersMu.Unlock()
+
+	// every namespace bootstraps on its own, so one waiting for the controller
+	// does not hold up the others; deliveries left pending by the previous run
+	// resume right away
+	for _, worker := range a.cfg.WorkerTargets() {
+		a.addWorker(worker, true)
+	}
+
+	if a.discovery != nil {
+		go a.discovery.Run(ctx)
+	}
+
+	if a.cfg.API.Listen != "" {
+		server := api.NewServer(a.cfg.API.Listen, a, a.cfg.Discovery.Token, a.discovery)
+		go func() {
+			if err := server.Run(ctx); err != nil {
+				a.fail(fmt.Errorf("agent API: %w", err))
+			}
+		}()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-a.errCh:
+	}
+
+	log.Println("[Agent] Shutting down...")
+	a.workersMu.Lock()
+	for _, ns := range a.namespaces {
+		ns.stop()
+	}
+	a.workersMu.Unlock()
+
+	return err
+}
+
+// fail stops Run with err unless it is already stopping
+func (a *Agent) fail(err error) {
+	select {
+	case a.errCh <- err:
+	default:
+	}
+}
+
+// call runs a controller or worker request under the retry policy
+func (a *Agent) call(ctx context.Context, policy retry.Policy, what string, op func(ctx context.Context) error) error {
+	return policy.Do(ctx, op, func(err error, wait time.Duration) {
+		log.Printf("[Agent] %s failed, retrying in %v: %v", what, wait.Round(time.Millisecond), err)
+	})
+}
+
+// token returns the current controller credential
+func (a *Agent) token() string {
+	a.credentialMu.RLock()
+	defer a.credentialMu.RUnlock()
+	return a.credential.Credential
+}
+
+func (a *Agent) setCredential(credential *domainAgents.Credential) {
+	a.credentialMu.Lock()
+	a.credential = credential
+	a.credentialMu.Unlock()
+}
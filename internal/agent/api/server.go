@@ -0,0 +1,174 @@
+// Package api is the agent's local HTTP API: status and control for
+// operators on the host, and worker announcements for discovery
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"distributed_system/internal/agent/discovery"
+	"distributed_system/internal/domain/agents"
+	"distributed_system/internal/domain/worker"
+	"distributed_system/pkg/errors"
+	"distributed_system/pkg/response"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Server struct {
+	srv *http.Server
+}
+
+// NewServer serves the agent API on listen. Everything except /workers only
+// answers loopback callers. /workers is served when discovery is enabled:
+// workers present discoveryToken, or must be local when it is empty.
+func NewServer(listen string, runtime Runtime, discoveryToken string, discovery *discovery.Discovery) *Server {
+	r := gin.New()
+	r.Use(gin.Recovery())
+
+	local := r.Group("")
+	{
+		local.Use(localOnly())
+
+		local.GET("/health", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  "healthy",
+				"service": "agent",
+			})
+		})
+
+		local.GET("/ready", func(c *gin.Context) {
+			if err := runtime.Ready(); err != nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"status": "not ready",
+					"reason": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"status": "ready"})
+		})
+
+		local.GET("/status", func(c *gin.Context) {
+			response.Success(c, runtime.Status())
+		})
+
+		local.GET("/config", func(c *gin.Context) {
+			cfg, err := runtime.Config(c.DefaultQuery("namespace", agents.DefaultNamespace))
+			if err != nil {
+				response.Error(c, err)
+				return
+			}
+			response.Success(c, cfg)
+		})
+
+		local.POST("/sync", func(c *gin.Context) {
+			if err := runtime.Sync(c.Request.Context(), c.Query("namespace")); err != nil {
+				response.Error(c, err)
+				return
+			}
+			response.SuccessWithMessage(c, "Config synced", runtime.Status().Namespaces)
+		})
+	}
+
+	if discovery != nil {
+		workers := r.Group("/workers")
+		{
+			workers.Use(workerAuth(discoveryToken))
+			workers.GET("", func(c *gin.Context) {
+				response.Success(c, discovery.Workers())
+			})
+			workers.POST("", func(c *gin.Context) {
+				var announcement worker.Announcement
+				if err := c.ShouldBindJSON(&announcement); err != nil {
+					response.BindingError(c, err)
+					return
+				}
+
+				if err := discovery.Announce(announcement); err != nil {
+					response.Error(c, err)
+					return
+				}
+				response.SuccessWithMessage(c, "Worker registered", nil)
+			})
+			workers.DELETE("", func(c *gin.Context) {
+				url := c.Query("url")
+				if url == "" {
+					response.Error(c, errors.InvalidInput("url is required"))
+					return
+				}
+
+				discovery.Withdraw(url)
+				response.SuccessWithMessage(c, "Worker removed", nil)
+			})
+		}
+	}
+
+	return &Server{srv: &http.Server{
+		Addr:              listen,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}}
+}
+
+// Run serves until ctx is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("[Agent] API listening on %s", s.srv.Addr)
+		errCh <- s.srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(shutdownCtx)
+}
+
+// localOnly rejects callers from other hosts, even when the API listens on
+// all interfaces for discovery
+func localOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isLoopback(c.Request.RemoteAddr) {
+			response.Forbidden(c, "the agent API only answers local requests")
+			c.Abort()
+		}
+	}
+}
+
+// workerAuth checks the discovery token, or that the caller is on this host
+// when there is no token
+func workerAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			if !isLoopback(c.Request.RemoteAddr) {
+				response.Forbidden(c, "only local workers can register without a discovery token")
+				c.Abort()
+			}
+			return
+		}
+
+		presented := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			response.Unauthorized(c, "invalid discovery token")
+			c.Abort()
+		}
+	}
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"distributed_system/internal/agent/delivery"
+	"distributed_system/internal/agent/discovery"
+	"distributed_system/internal/domain/config"
+	"time"
+)
+
+// Runtime is the agent as seen by its API
+type Runtime interface {
+	Status() Status
+	// Config returns the config the agent holds for a namespace
+	Config(namespace string) (*config.Config, error)
+	// Sync fetches the namespace's config now and pushes it to its workers,
+	// all namespaces when namespace is empty
+	Sync(ctx context.Context, namespace string) error
+	// Ready returns why the agent is not ready to serve its workers, or nil
+	Ready() error
+}
+
+// Status is the agent state reported on GET /status
+type Status struct {
+	AgentID      string            `json:"agent_id"`
+	AgentVersion string            `json:"agent_version"`
+	StartedAt    time.Time         `json:"started_at"`
+	Controller   ControllerStatus  `json:"controller"`
+	Namespaces   []NamespaceStatus `json:"namespaces"`
+	Deliveries   []delivery.Status `json:"deliveries"`
+	// Discovered is only reported when discovery is enabled
+	Discovered []discovery.Worker `json:"discovered,omitempty"`
+}
+
+// ControllerStatus is the outcome of the latest config fetch of any namespace
+type ControllerStatus struct {
+	URL           string     `json:"url"`
+	Reachable     bool       `json:"reachable"`
+	LastFetchAt   *time.Time `json:"last_fetch_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// NamespaceStatus is the config the agent holds for a namespace and how its
+// latest fetch went
+type NamespaceStatus struct {
+	Namespace     string     `json:"namespace"`
+	Version       int        `json:"version"`
+	ConfigURL     string     `json:"config_url,omitempty"`
+	LastFetchAt   *time.Time `json:"last_fetch_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	Workers       int        `json:"workers"`
+}
@@ -1,68 +1,156 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"distributed_system/internal/domain/agents"
 	"distributed_system/internal/domain/config"
+	"distributed_system/pkg/errors"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"time"
+	"net/url"
+	"sync/atomic"
 )
 
 // ConfigClient handles HTTP communication with the controller
 type ConfigClient struct {
 	baseURL    string
 	httpClient *http.Client
+	// rotateRequested is set when the controller answers with X-Credential-Rotate
+	rotateRequested atomic.Bool
 }
 
 // NewConfigClient creates a new config client
-func NewConfigClient(baseURL string) *ConfigClient {
+func NewConfigClient(baseURL string, httpClient *http.Client) *ConfigClient {
 	return &ConfigClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		baseURL:    baseURL,
+		httpClient: httpClient,
 	}
 }
 
-// GetLatestConfig fetches the latest config from the controller
-func (c *ConfigClient) GetLatestConfig(ctx context.Context, token string) (*config.Config, error) {
-	url := fmt.Sprintf("%s/config/agent", c.baseURL)
+// GetLatestConfig fetches the latest config of a namespace from the controller
+func (c *ConfigClient) GetLatestConfig(ctx context.Context, token, namespace string) (*config.Config, error) {
+	var latest config.Config
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	header, err := c.do(ctx, http.MethodGet, "/config/agent?namespace="+url.QueryEscape(namespace), token, nil, &latest)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Get("X-Credential-Rotate") == "true" {
+		c.rotateRequested.Store(true)
+	}
+
+	return &latest, nil
+}
+
+// RotateRequested reports whether the controller asked for a new credential
+func (c *ConfigClient) RotateRequested() bool {
+	return c.rotateRequested.Load()
+}
+
+// Register enrolls the agent with a registration token
+func (c *ConfigClient) Register(ctx context.Context, token string, input *agents.InputRegister) (*agents.Credential, error) {
+	var credential agents.Credential
+
+	if _, err := c.do(ctx, http.MethodPost, "/agent/register", token, input, &credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+// RotateCredential exchanges the current credential for a new one
+func (c *ConfigClient) RotateCredential(ctx context.Context, token string) (*agents.Credential, error) {
+	var credential agents.Credential
+
+	if _, err := c.do(ctx, http.MethodPost, "/agent/credential/rotate", token, nil, &credential); err != nil {
+		return nil, err
+	}
+
+	c.rotateRequested.Store(false)
+	return &credential, nil
+}
+
+// RenewCertificate sends a CSR and returns the newly issued client certificate
+func (c *ConfigClient) RenewCertificate(ctx context.Context, token string, csrPEM []byte) (*agents.Certificate, error) {
+	var certificate agents.Certificate
+
+	input := agents.InputRenewCertificate{CSR: string(csrPEM)}
+	if _, err := c.do(ctx, http.MethodPost, "/agent/certificate/renew", token, input, &certificate); err != nil {
+		return nil, err
+	}
+
+	return &certificate, nil
+}
+
+// do sends a JSON request and decodes the data field of a response.Success body
+func (c *ConfigClient) do(ctx context.Context, method, path, token string, input, output interface{}) (http.Header, error) {
+	var body io.Reader
+	if input != nil {
+		jsonData, err := json.Marshal(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Set authorization header with bearer token
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, transportError("controller", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
-	}
-
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, transportError("controller", err)
 	}
 
-	// Parse response - expecting {"data": {...}} format from response.Success
-	var response struct {
-		Data config.Config `json:"data"`
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("controller", resp.StatusCode, respBody)
 	}
 
-	if err := json.Unmarshal(body, &response); err != nil {
+	response := struct {
+		Data interface{} `json:"data"`
+	}{Data: output}
+
+	if err := json.Unmarshal(respBody, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return &response.Data, nil
+	return resp.Header, nil
+}
+
+// transportError marks network failures as retryable
+func transportError(service string, err error) error {
+	return errors.Wrap(err, errors.ErrCodeExternalService, fmt.Sprintf("%s request failed", service))
+}
+
+// statusError classifies an unexpected answer for the retry policy: 408, 429
+// and 5xx are retryable, other statuses will not change by trying again
+func statusError(service string, status int, body []byte) error {
+	err := fmt.Errorf("unexpected status code %d: %s", status, string(body))
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		return errors.Wrap(err, errors.ErrCodeRateLimit, fmt.Sprintf("%s is rate limiting", service)).WithStatus(status)
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return errors.Wrap(err, errors.ErrCodeTimeout, fmt.Sprintf("%s timed out", service)).WithStatus(status)
+	case status >= http.StatusInternalServerError:
+		return errors.Wrap(err, errors.ErrCodeExternalService, fmt.Sprintf("%s is unavailable", service)).WithStatus(status)
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return errors.Wrap(err, errors.ErrCodeUnauthorized, fmt.Sprintf("%s rejected the credential", service)).WithStatus(status)
+	default:
+		return errors.Wrap(err, errors.ErrCodeInvalidInput, fmt.Sprintf("%s rejected the request", service)).WithStatus(status)
+	}
 }
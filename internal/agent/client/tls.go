@@ -0,0 +1,225 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"distributed_system/internal/config"
+	domainAgents "distributed_system/internal/domain/agents"
+	"distributed_system/pkg/pki"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	certFile   = "agent-cert.pem"
+	keyFile    = "agent-key.pem"
+	caCertFile = "agent-ca.pem"
+)
+
+// Files are the agent's state files the TLS material is kept in
+type Files interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	RemoveFile(name string) error
+}
+
+// TLS holds the current client certificate and trusted CAs so renewals take
+// effect without rebuilding HTTP clients. A nil *TLS means mTLS is disabled.
+type TLS struct {
+	files Files
+	// mu guards the fields below; they are replaced, never modified, so
+	// handshakes can keep using what they read
+	mu    sync.RWMutex
+	cert  *tls.Certificate
+	leaf  *x509.Certificate
+	roots *x509.CertPool
+}
+
+// LoadTLS trusts the bootstrap CA plus the CA from a previous enrollment and
+// loads the client certificate stored in files if there is one. It returns
+// nil when mTLS is disabled.
+func LoadTLS(cfg config.AgentTLS, files Files) (*TLS, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	// the pool is built before t is shared, later changes swap in a new one
+	roots := x509.NewCertPool()
+
+	if cfg.CAFile != "" {
+		caPEM, err := os.ReadFile(cfg.CAFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading CA %s: %w", cfg.CAFile, err)
+		}
+		roots.AppendCertsFromPEM(caPEM)
+	}
+
+	caPEM, err := files.ReadFile(caCertFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading CA %s: %w", caCertFile, err)
+	}
+	roots.AppendCertsFromPEM(caPEM)
+
+	t := &TLS{files: files, roots: roots}
+
+	certPEM, certErr := files.ReadFile(certFile)
+	keyPEM, keyErr := files.ReadFile(keyFile)
+	if certErr != nil || keyErr != nil {
+		return t, nil
+	}
+
+	// the key and certificate are separate writes, so a crash during Store
+	// can leave a key without its certificate; the agent then requests a new
+	// certificate instead of failing every start
+	if err := t.load(certPEM, keyPEM); err != nil {
+		log.Printf("[Agent] Warning: discarding stored client certificate: %v", err)
+	}
+
+	return t, nil
+}
+
+// Reset removes the client certificate and key; the CA stays trusted
+func (t *TLS) Reset() error {
+	if t == nil {
+		return nil
+	}
+
+	for _, file := range []string{certFile, keyFile} {
+		if err := t.files.RemoveFile(file); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+
+	t.mu.Lock()
+	t.cert = nil
+	t.leaf = nil
+	t.mu.Unlock()
+	return nil
+}
+
+// HTTPClient returns a client presenting the current certificate, or a plain
+// client when mTLS is disabled
+func (t *TLS) HTTPClient(timeout time.Duration) *http.Client {
+	if t == nil {
+		return &http.Client{Timeout: timeout}
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: t.config(),
+		},
+	}
+}
+
+// config verifies servers against the CAs trusted at handshake time. RootCAs
+// is fixed for a tls.Config, so the chain is checked in VerifyConnection
+// instead, the way crypto/tls documents for custom verification.
+func (t *TLS) config() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+		VerifyConnection:   t.verifyConnection,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			t.mu.RLock()
+			defer t.mu.RUnlock()
+			if t.cert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return t.cert, nil
+		},
+	}
+}
+
+func (t *TLS) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server presented no certificate")
+	}
+
+	t.mu.RLock()
+	roots := t.roots
+	t.mu.RUnlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+func (t *TLS) load(certPEM, keyPEM []byte) error {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("error loading client certificate: %w", err)
+	}
+
+	leaf, err := pki.ParseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.cert = &cert
+	t.leaf = leaf
+	t.mu.Unlock()
+
+	return nil
+}
+
+// NeedsCertificate is true when mTLS is enabled and there is no certificate
+// yet or it is in its last third of validity
+func (t *TLS) NeedsCertificate() bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.leaf == nil || pki.ShouldRenew(t.leaf, time.Now())
+}
+
+// HasCertificate reports whether a client certificate is loaded
+func (t *TLS) HasCertificate() bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.cert != nil
+}
+
+// Store persists an issued certificate with its key and starts using it
+func (t *TLS) Store(certificate *domainAgents.Certificate, keyPEM []byte) error {
+	if err := t.files.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return fmt.Errorf("error saving client key: %w", err)
+	}
+	if err := t.files.WriteFile(certFile, []byte(certificate.Certificate), 0644); err != nil {
+		return fmt.Errorf("error saving client certificate: %w", err)
+	}
+	if err := t.files.WriteFile(caCertFile, []byte(certificate.CACertificate), 0644); err != nil {
+		return fmt.Errorf("error saving CA certificate: %w", err)
+	}
+
+	if err := t.load([]byte(certificate.Certificate), keyPEM); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	roots := t.roots.Clone()
+	roots.AppendCertsFromPEM([]byte(certificate.CACertificate))
+	t.roots = roots
+	t.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"distributed_system/internal/domain/config"
+	"distributed_system/internal/domain/worker"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// WorkerClient pushes configs to the workers behind the agent
+type WorkerClient struct {
+	httpClient *http.Client
+
+	// signingKeys are forwarded with every push, see SetConfigSigningKeys
+	signingKeysMu sync.RWMutex
+	signingKeys   []string
+}
+
+// NewWorkerClient creates a new worker client
+func NewWorkerClient(httpClient *http.Client) *WorkerClient {
+	return &WorkerClient{httpClient: httpClient}
+}
+
+// SetConfigSigningKeys sets the controller keys the agent verifies configs
+// with; they are forwarded so workers without trusted keys can pin them
+func (c *WorkerClient) SetConfigSigningKeys(keys []string) {
+	c.signingKeysMu.Lock()
+	c.signingKeys = keys
+	c.signingKeysMu.Unlock()
+}
+
+// PushConfig sends a signed config for a namespace to the worker's private
+// /config endpoint and returns the version the worker acknowledged
+func (c *WorkerClient) PushConfig(ctx context.Context, workerURL, internalKey, namespace string, cfg *config.Config) (int, error) {
+	c.signingKeysMu.RLock()
+	signingKeys := c.signingKeys
+	c.signingKeysMu.RUnlock()
+
+	jsonData, err := json.Marshal(worker.UpdateConfigRequest{
+		ConfigURL:         cfg.ConfigURL,
+		PoolingInterval:   cfg.PoolingInterval,
+		Version:           cfg.Version,
+		UUID:              cfg.UUID,
+		Signature:         cfg.Signature,
+		SignatureKeyID:    cfg.SignatureKeyID,
+		Namespace:         namespace,
+		ConfigSigningKeys: signingKeys,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling config: %w", err)
+	}
+
+	return c.version(ctx, http.MethodPost, workerURL+"/config", internalKey, bytes.NewReader(jsonData))
+}
+
+// AppliedVersion asks the worker which config version it runs for a
+// namespace, 0 for none
+func (c *WorkerClient) AppliedVersion(ctx context.Context, workerURL, internalKey, namespace string) (int, error) {
+	return c.version(ctx, http.MethodGet, workerURL+"/config/version?namespace="+url.QueryEscape(namespace), internalKey, nil)
+}
+
+// version sends a request to an endpoint answering with the applied version
+func (c *WorkerClient) version(ctx context.Context, method, url, internalKey string, body io.Reader) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+internalKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, transportError("worker", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, transportError("worker", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, statusError("worker", resp.StatusCode, respBody)
+	}
+
+	var response struct {
+		Data struct {
+			Version int `json:"version"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return 0, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return response.Data.Version, nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"crypto/rand"
+	configEnv "distributed_system/internal/config"
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// encryptionFile describes how the key of an encrypted state directory is
+// derived. It holds no secret.
+const encryptionFile = "encryption.json"
+
+const (
+	keySourceFile       = "key_file"
+	keySourcePassphrase = "passphrase"
+)
+
+// checkValue is sealed into encryptionFile so a wrong key is reported as such
+// instead of as corrupted state files
+const checkValue = "agent-state"
+
+type encryptionParams struct {
+	Cipher    string `json:"cipher"`
+	KeySource string `json:"key_source"`
+	Salt      []byte `json:"salt"`
+	Check     []byte `json:"check"`
+}
+
+// openSealer returns the sealer of the state directory, or nil when its state
+// is not encrypted. Encryption starts on the first open with a key configured.
+func openSealer(dir string, cfg configEnv.StateEncryption) (*crypto.Sealer, error) {
+	path := filepath.Join(dir, encryptionFile)
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if !cfg.Enabled() {
+			return nil, nil
+		}
+		return createSealer(path, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("the state in %s is encrypted, set state.encryption.key_file or AGENT_STATE_PASSPHRASE", dir)
+	}
+
+	var params encryptionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if params.KeySource != keySourceOf(cfg) {
+		return nil, fmt.Errorf("the state in %s is encrypted with key source %q, but %q is configured", dir, params.KeySource, keySourceOf(cfg))
+	}
+
+	sealer, err := newSealer(cfg, params.Salt, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sealer.Open(params.Check, []byte(encryptionFile)); err != nil {
+		return nil, fmt.Errorf("wrong state encryption key for %s", dir)
+	}
+
+	return sealer, nil
+}
+
+func createSealer(path string, cfg configEnv.StateEncryption) (*crypto.Sealer, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	sealer, err := newSealer(cfg, salt, true)
+	if err != nil {
+		return nil, err
+	}
+	check, err := sealer.Seal([]byte(checkValue), []byte(encryptionFile))
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(encryptionParams{
+		Cipher:    "aes-256-gcm",
+		KeySource: keySourceOf(cfg),
+		Salt:      salt,
+		Check:     check,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.WriteFileAtomic(path, raw, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save %s: %w", path, err)
+	}
+
+	return sealer, nil
+}
+
+// newSealer derives the key from the passphrase, which wins over the key file
+func newSealer(cfg configEnv.StateEncryption, salt []byte, create bool) (*crypto.Sealer, error) {
+	if cfg.Passphrase != "" {
+		return crypto.NewSealer(crypto.KeyFromPassphrase(cfg.Passphrase, salt))
+	}
+
+	secret, err := loadKeyFile(cfg.KeyFile, create)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.KeyFromSecret(secret, salt, checkValue)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.NewSealer(key)
+}
+
+// loadKeyFile reads the machine-local secret. It is only generated for a new
+// encrypted state; a missing key file of an existing one is an error.
+func loadKeyFile(path string, create bool) ([]byte, error) {
+	secret, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		secret = make([]byte, crypto.SealerKeySize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create state key file: %w", err)
+		}
+		if err := utils.WriteFileAtomic(path, secret, 0600); err != nil {
+			return nil, fmt.Errorf("failed to create state key file: %w", err)
+		}
+		return secret, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state key file: %w", err)
+	}
+
+	if len(secret) < crypto.SealerKeySize {
+		return nil, fmt.Errorf("state key file %s must hold at least %d bytes", path, crypto.SealerKeySize)
+	}
+	return secret, nil
+}
+
+func keySourceOf(cfg configEnv.StateEncryption) string {
+	if cfg.Passphrase != "" {
+		return keySourcePassphrase
+	}
+	return keySourceFile
+}
@@ -0,0 +1,29 @@
+//go:build !unix
+
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// acquireLock creates path exclusively and writes the pid into it. Without
+// flock the file outlives a crash; remove it by hand once no agent runs.
+func acquireLock(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		owner, _ := os.ReadFile(path)
+		return nil, fmt.Errorf("in use by another agent (pid %s), remove %s if it is not running", owner, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	return f, nil
+}
+
+func releaseLock(f *os.File) error {
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package config
+
+// restrictDir is a no-op without unix permissions
+func restrictDir(dir string) error {
+	return nil
+}
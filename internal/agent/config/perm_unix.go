@@ -0,0 +1,30 @@
+//go:build unix
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+)
+
+// restrictDir sets a state directory owned by the agent's user to mode 0700;
+// MkdirAll leaves the mode of an existing directory alone
+func restrictDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || int(stat.Uid) != os.Geteuid() || info.Mode().Perm() == 0700 {
+		return nil
+	}
+
+	if err := os.Chmod(dir, 0700); err != nil {
+		return fmt.Errorf("failed to restrict state directory %s: %w", dir, err)
+	}
+	log.Printf("[Agent] Restricted the state directory %s to mode 0700", dir)
+	return nil
+}
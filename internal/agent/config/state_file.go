@@ -0,0 +1,280 @@
+package config
+
+import (
+	"distributed_system/pkg/utils"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stateVersion is the layout of the state files this agent writes. Files
+// from older agents are upgraded through migrations when they are read.
+//
+//	1: plain JSON, credential.json may hold only a credential_key
+//	2: {"state_version": 2, "data": ...}
+//	3: data may be replaced by sealed, the encrypted data
+const stateVersion = 3
+
+// stateEnvelope wraps the content of every state file
+type stateEnvelope struct {
+	StateVersion int             `json:"state_version"`
+	Data         json.RawMessage `json:"data,omitempty"`
+	// Sealed replaces Data when the state directory is encrypted; the file
+	// name is authenticated with it so files cannot be swapped
+	Sealed []byte `json:"sealed,omitempty"`
+}
+
+// sealedBlockType is the PEM block of an encrypted raw file (see ReadFile)
+const sealedBlockType = "AGENT STATE"
+
+// migrations upgrade the data of a state file from the version they are
+// keyed by to the next one
+var migrations = map[int]func(name string, data json.RawMessage) (json.RawMessage, error){
+	1: migrateV1,
+	2: keepData,
+}
+
+// keepData is the migration of versions that only changed the envelope
+func keepData(name string, data json.RawMessage) (json.RawMessage, error) {
+	return data, nil
+}
+
+// migrateV1 turns the credential layout from before credential rotation into
+// a credential; the other files keep their content
+func migrateV1(name string, data json.RawMessage) (json.RawMessage, error) {
+	if name != credentialFile {
+		return data, nil
+	}
+
+	var legacy struct {
+		Credential    string `json:"credential"`
+		CredentialKey string `json:"credential_key"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+	if legacy.Credential != "" || legacy.CredentialKey == "" {
+		return data, nil
+	}
+
+	return json.Marshal(map[string]string{"credential": legacy.CredentialKey})
+}
+
+// secretFiles are only readable by the agent's user
+var secretFiles = map[string]bool{
+	credentialFile: true,
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// read decodes the state file name into v, decrypting it and upgrading it
+// first when an older agent wrote it. A file still in plain text is encrypted
+// when the state directory is. It returns false when the file does not exist.
+func (s *Store) read(name string, v interface{}) (bool, error) {
+	raw, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	envelope, err := decodeEnvelope(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", s.path(name), err)
+	}
+	version := envelope.StateVersion
+	if version > stateVersion {
+		return false, fmt.Errorf("%s was written by a newer agent (state version %d, supported %d)", s.path(name), version, stateVersion)
+	}
+
+	data, err := s.open(name, envelope)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", s.path(name), err)
+	}
+
+	if version < stateVersion || (s.sealer != nil && envelope.Sealed == nil) {
+		for ; version < stateVersion; version++ {
+			if data, err = migrations[version](name, data); err != nil {
+				return false, fmt.Errorf("failed to migrate %s from state version %d: %w", s.path(name), version, err)
+			}
+		}
+		if err := s.writeRaw(name, data); err != nil {
+			return false, fmt.Errorf("failed to save migrated %s: %w", s.path(name), err)
+		}
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("%s: %w", s.path(name), err)
+	}
+	return true, nil
+}
+
+// write replaces the state file name atomically
+func (s *Store) write(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.writeRaw(name, data)
+}
+
+func (s *Store) writeRaw(name string, data json.RawMessage) error {
+	envelope := stateEnvelope{StateVersion: stateVersion, Data: data}
+	if s.sealer != nil {
+		sealed, err := s.sealer.Seal(data, []byte(name))
+		if err != nil {
+			return err
+		}
+		envelope = stateEnvelope{StateVersion: stateVersion, Sealed: sealed}
+	}
+
+	raw, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0644)
+	if secretFiles[name] {
+		perm = 0600
+	}
+	return utils.WriteFileAtomic(s.path(name), raw, perm)
+}
+
+// open returns the data of an envelope, decrypting it when it is sealed
+func (s *Store) open(name string, envelope *stateEnvelope) (json.RawMessage, error) {
+	if envelope.Sealed == nil {
+		return envelope.Data, nil
+	}
+	if s.sealer == nil {
+		return nil, errors.New("the file is encrypted but no state encryption key is configured")
+	}
+	return s.sealer.Open(envelope.Sealed, []byte(name))
+}
+
+// decodeEnvelope returns the envelope of a file; files without one are
+// version 1
+func decodeEnvelope(raw []byte) (*stateEnvelope, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["state_version"]; !ok {
+		return &stateEnvelope{StateVersion: 1, Data: raw}, nil
+	}
+
+	var envelope stateEnvelope
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return nil, err
+	}
+	return &envelope, nil
+}
+
+// ReadFile returns a file of the state directory written by WriteFile, such
+// as the TLS material, decrypting it when it is sealed. A file still in plain
+// text is encrypted when the state directory is.
+func (s *Store) ReadFile(name string) ([]byte, error) {
+	path := filepath.Join(s.dir, name)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != sealedBlockType {
+		if s.sealer != nil {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.WriteFile(name, raw, info.Mode().Perm()); err != nil {
+				return nil, fmt.Errorf("failed to encrypt %s: %w", path, err)
+			}
+		}
+		return raw, nil
+	}
+
+	if s.sealer == nil {
+		return nil, fmt.Errorf("%s is encrypted but no state encryption key is configured", path)
+	}
+	data, err := s.sealer.Open(block.Bytes, []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// WriteFile replaces a file of the state directory atomically, sealed in a
+// PEM block when the state directory is encrypted
+func (s *Store) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if s.sealer != nil {
+		sealed, err := s.sealer.Seal(data, []byte(name))
+		if err != nil {
+			return err
+		}
+		data = pem.EncodeToMemory(&pem.Block{Type: sealedBlockType, Bytes: sealed})
+	}
+	return utils.WriteFileAtomic(filepath.Join(s.dir, name), data, perm)
+}
+
+// RemoveFile removes a file of the state directory, if it exists
+func (s *Store) RemoveFile(name string) error {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sealAll encrypts the state files written before encryption was enabled.
+// Only the agent's own files are touched, other files in the directory are
+// left alone.
+func (s *Store) sealAll() error {
+	for _, pattern := range stateFiles {
+		files, err := filepath.Glob(filepath.Join(s.dir, pattern))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			name := filepath.Base(file)
+			if strings.HasSuffix(name, ".json") {
+				var data json.RawMessage
+				if _, err := s.read(strings.TrimSuffix(name, ".json"), &data); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if _, err := s.ReadFile(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// moveFile renames src to dst, copying when they are on different devices
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(dst, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"distributed_system/internal/agent/delivery"
+	configEnv "distributed_system/internal/config"
+	"distributed_system/internal/domain/agents"
+	domainConfig "distributed_system/internal/domain/config"
+	"distributed_system/pkg/crypto"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	credentialFile = "credential"
+	configFile     = "config"
+	deliveriesFile = "deliveries"
+	lockFile       = "agent.lock"
+)
+
+// Store keeps the agent's local state between restarts in its state
+// directory: the credential from enrollment (credential.json), the last
+// config received from the controller for every namespace (config.json for
+// the default one, config-<namespace>.json for the others) and the delivery
+// state of every worker (deliveries.json). Files are replaced atomically and
+// the directory is locked, so two agents cannot share it. With a key
+// configured every file is encrypted, see encryption.json.
+type Store struct {
+	dir  string
+	lock *os.File
+	// sealer is nil when the state is stored in plain text
+	sealer *crypto.Sealer
+
+	// deliveriesMu serializes the read-modify-write of deliveries.json
+	deliveriesMu sync.Mutex
+}
+
+// OpenStore creates and locks the state directory. State files an older agent
+// left in the working directory are moved into it, and are encrypted along
+// with the rest of the state when encryption is configured.
+func OpenStore(dir string, encryption configEnv.StateEncryption) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := restrictDir(dir); err != nil {
+		return nil, err
+	}
+
+	lock, err := acquireLock(filepath.Join(dir, lockFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock state directory %s: %w", dir, err)
+	}
+
+	s := &Store{dir: dir, lock: lock}
+	if err := s.adoptWorkingDirState(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	if s.sealer, err = openSealer(dir, encryption); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if s.sealer != nil {
+		if err := s.sealAll(); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to encrypt the state: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Close releases the state directory
+func (s *Store) Close() error {
+	return releaseLock(s.lock)
+}
+
+// LoadCredential returns the stored credential, or nil when the agent has not
+// enrolled yet
+func (s *Store) LoadCredential() (*agents.Credential, error) {
+	var credential agents.Credential
+	found, err := s.read(credentialFile, &credential)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential: %w", err)
+	}
+	if !found || credential.Credential == "" {
+		return nil, nil
+	}
+	return &credential, nil
+}
+
+func (s *Store) SaveCredential(credential *agents.Credential) error {
+	if err := s.write(credentialFile, credential); err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+	return nil
+}
+
+// LoadConfig returns the last config of the namespace written by SaveConfig,
+// or nil when there is none
+func (s *Store) LoadConfig(namespace string) (*domainConfig.Config, error) {
+	var cfg domainConfig.Config
+	found, err := s.read(configFileOf(namespace), &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &cfg, nil
+}
+
+func (s *Store) SaveConfig(namespace string, cfg *domainConfig.Config) error {
+	if err := s.write(configFileOf(namespace), cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+// configFileOf keeps config.json for the default namespace so caches from
+// before namespaces still load
+func configFileOf(namespace string) string {
+	if namespace == agents.DefaultNamespace {
+		return configFile
+	}
+	return configFile + "-" + url.PathEscape(namespace)
+}
+
+// Reset removes all local state; the lock and the encryption key stay
+func (s *Store) Reset() error {
+	s.deliveriesMu.Lock()
+	defer s.deliveriesMu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, configFile+"-*.json"))
+	if err != nil {
+		return err
+	}
+	files = append(files, s.path(credentialFile), s.path(configFile), s.path(deliveriesFile))
+
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// LoadDelivery returns the saved delivery status of a queue (see
+// delivery.Target.Key), or nil
+func (s *Store) LoadDelivery(key string) (*delivery.Status, error) {
+	s.deliveriesMu.Lock()
+	defer s.deliveriesMu.Unlock()
+
+	deliveries, err := s.loadDeliveries()
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries[key], nil
+}
+
+func (s *Store) SaveDelivery(status *delivery.Status) error {
+	s.deliveriesMu.Lock()
+	defer s.deliveriesMu.Unlock()
+
+	deliveries, err := s.loadDeliveries()
+	if err != nil {
+		return err
+	}
+	deliveries[status.Key()] = status
+
+	if err := s.write(deliveriesFile, deliveries); err != nil {
+		return fmt.Errorf("failed to save delivery state: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) loadDeliveries() (map[string]*delivery.Status, error) {
+	var deliveries map[string]*delivery.Status
+	if _, err := s.read(deliveriesFile, &deliveries); err != nil {
+		return nil, fmt.Errorf("failed to read delivery state: %w", err)
+	}
+	if deliveries == nil {
+		return map[string]*delivery.Status{}, nil
+	}
+	return deliveries, nil
+}
+
+// stateFiles are the files the agent keeps in its state directory, besides
+// the lock and encryption.json. Agents wrote them to the working directory
+// before the state directory existed.
+var stateFiles = []string{
+	credentialFile + ".json",
+	configFile + ".json",
+	configFile + "-*.json",
+	deliveriesFile + ".json",
+	"agent-cert.pem",
+	"agent-key.pem",
+	"agent-ca.pem",
+}
+
+// adoptWorkingDirState moves legacy state files from the working directory
+// into a state directory that does not have them yet. The names are generic,
+// so nothing is moved unless the working directory holds an agent credential.
+func (s *Store) adoptWorkingDirState() error {
+	dir, err := filepath.Abs(s.dir)
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil || wd == dir || !isCredentialFile(credentialFile+".json") {
+		return nil
+	}
+
+	for _, pattern := range stateFiles {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range matches {
+			target := filepath.Join(s.dir, file)
+			if _, err := os.Stat(target); err == nil {
+				continue
+			}
+
+			if err := moveFile(file, target); err != nil {
+				return fmt.Errorf("failed to move %s into the state directory: %w", file, err)
+			}
+			log.Printf("[Agent] Moved %s into the state directory %s", file, s.dir)
+		}
+	}
+	return nil
+}
+
+// isCredentialFile reports whether path holds a credential written by any
+// agent version, plain or in a state envelope
+func isCredentialFile(path string) bool {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+	for _, field := range []string{"state_version", "credential", "credential_key"} {
+		if _, ok := fields[field]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,288 @@
+// Package delivery gets configs onto workers reliably. Every worker and
+// namespace pair has a queue holding at most one pending config, which is pushed with backoff until
+// the worker acknowledges that exact version. The queue state is persisted so
+// a restart resumes unfinished deliveries.
+package delivery
+
+import (
+	"context"
+	"distributed_system/internal/domain/agents"
+	"distributed_system/internal/domain/config"
+	"distributed_system/pkg/errors"
+	"distributed_system/pkg/retry"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// StatePending is a config waiting to be acknowledged, including retries
+	StatePending = "pending"
+	// StateDelivered means the worker acknowledged the pending version
+	StateDelivered = "delivered"
+	// StateFailed is a push the worker rejected for good (e.g. a wrong internal
+	// key or signature); it is retried on the next verification or version
+	StateFailed = "failed"
+)
+
+// Status is the delivery state of one namespace on one worker
+type Status struct {
+	Worker         string     `json:"worker"`
+	Namespace      string     `json:"namespace"`
+	State          string     `json:"state"`
+	PendingVersion int        `json:"pending_version"`
+	AppliedVersion int        `json:"applied_version"`
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	// Config is the pending config, kept so a restart can deliver it
+	Config *config.Config `json:"config,omitempty"`
+}
+
+// Key identifies the queue the status belongs to. The default namespace is
+// keyed by the worker URL alone, as before workers had namespaces.
+func (s *Status) Key() string {
+	return Target{URL: s.Worker, Namespace: s.Namespace}.Key()
+}
+
+// Target is a namespace on a worker the agent pushes configs to
+type Target struct {
+	URL         string
+	InternalKey string
+	Namespace   string
+}
+
+func (t Target) Key() string {
+	if t.Namespace == "" || t.Namespace == agents.DefaultNamespace {
+		return t.URL
+	}
+	return t.URL + "#" + t.Namespace
+}
+
+// Pusher talks to workers
+type Pusher interface {
+	// PushConfig returns the version the worker acknowledged
+	PushConfig(ctx context.Context, workerURL, internalKey, namespace string, cfg *config.Config) (int, error)
+	AppliedVersion(ctx context.Context, workerURL, internalKey, namespace string) (int, error)
+}
+
+// State persists delivery status across restarts
+type State interface {
+	LoadDelivery(key string) (*Status, error)
+	SaveDelivery(status *Status) error
+}
+
+type Queue struct {
+	target Target
+	pusher Pusher
+	policy retry.Policy
+	state  State
+
+	mu     sync.Mutex
+	status Status
+	// cancelAttempt aborts the retries of a version that was superseded
+	cancelAttempt context.CancelFunc
+	wake          chan struct{}
+}
+
+// NewQueue restores the persisted status of the target's queue
+func NewQueue(target Target, pusher Pusher, policy retry.Policy, state State) *Queue {
+	q := &Queue{
+		target: target,
+		pusher: pusher,
+		policy: policy.WithoutLimit(),
+		state:  state,
+		status: Status{Worker: target.URL, Namespace: target.Namespace, State: StateDelivered},
+		wake:   make(chan struct{}, 1),
+	}
+
+	saved, err := state.LoadDelivery(target.Key())
+	if err != nil {
+		log.Printf("[Delivery] Warning: %v", err)
+	}
+	if saved != nil {
+		q.status = *saved
+		q.status.Namespace = target.Namespace
+		if q.status.State == StatePending {
+			log.Printf("[Delivery] Resuming delivery of version %d to %s", q.status.PendingVersion, target.Key())
+			q.signal()
+		}
+	}
+
+	return q
+}
+
+// Enqueue makes cfg the config to deliver, replacing a pending older one
+func (q *Queue) Enqueue(cfg *config.Config) {
+	q.mu.Lock()
+	if q.status.State == StatePending && q.status.PendingVersion > cfg.Version {
+		q.mu.Unlock()
+		return
+	}
+
+	q.status.State = StatePending
+	q.status.PendingVersion = cfg.Version
+	q.status.Config = cfg
+	q.status.Attempts = 0
+	q.status.LastError = ""
+	q.status.NextAttemptAt = nil
+	if q.cancelAttempt != nil {
+		q.cancelAttempt()
+	}
+	q.persist()
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+// Verify asks the worker which version it runs and delivers cfg again when
+// it differs, e.g. after the worker restarted and lost its config
+func (q *Queue) Verify(ctx context.Context, cfg *config.Config) {
+	q.mu.Lock()
+	pending := q.status.State == StatePending
+	q.mu.Unlock()
+	if pending {
+		return
+	}
+
+	applied, err := q.pusher.AppliedVersion(ctx, q.target.URL, q.target.InternalKey, q.target.Namespace)
+	if err != nil {
+		log.Printf("[Delivery] Could not check version of %s: %v", q.target.Key(), err)
+		return
+	}
+
+	q.mu.Lock()
+	q.status.AppliedVersion = applied
+	q.mu.Unlock()
+
+	if applied != cfg.Version {
+		log.Printf("[Delivery] Worker %s runs version %d, expected %d", q.target.Key(), applied, cfg.Version)
+		q.Enqueue(cfg)
+	}
+}
+
+// Status returns the current delivery state without the pending config
+func (q *Queue) Status() Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := q.status
+	status.Config = nil
+	return status
+}
+
+// Run delivers pending configs until ctx is cancelled
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		}
+
+		q.deliverPending(ctx)
+	}
+}
+
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverPending pushes the pending config until it is acknowledged, it fails
+// for good or a newer version replaces it
+func (q *Queue) deliverPending(ctx context.Context) {
+	q.mu.Lock()
+	if q.status.State != StatePending || q.status.Config == nil {
+		q.mu.Unlock()
+		return
+	}
+	cfg := q.status.Config
+	attemptCtx, cancel := context.WithCancel(ctx)
+	q.cancelAttempt = cancel
+	q.mu.Unlock()
+
+	defer cancel()
+
+	err := q.policy.Do(attemptCtx, func(ctx context.Context) error {
+		return q.push(ctx, cfg)
+	}, func(err error, wait time.Duration) {
+		q.recordRetry(cfg.Version, wait)
+		log.Printf("[Delivery] Pushing version %d to %s failed, retrying in %v: %v", cfg.Version, q.target.Key(), wait.Round(time.Millisecond), err)
+	})
+
+	// superseded or shutting down; a newer version has its own wake-up
+	if attemptCtx.Err() != nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.status.PendingVersion != cfg.Version {
+		return
+	}
+
+	now := time.Now()
+	if err != nil {
+		q.status.State = StateFailed
+		q.status.NextAttemptAt = nil
+		log.Printf("[Delivery] Giving up on version %d for %s: %v", cfg.Version, q.target.Key(), err)
+	} else {
+		q.status.State = StateDelivered
+		q.status.AppliedVersion = cfg.Version
+		q.status.LastError = ""
+		q.status.NextAttemptAt = nil
+		q.status.DeliveredAt = &now
+		q.status.Config = nil
+		log.Printf("[Delivery] Worker %s acknowledged version %d", q.target.Key(), cfg.Version)
+	}
+	q.persist()
+}
+
+// push makes one attempt; an acknowledgement of any other version is retried
+func (q *Queue) push(ctx context.Context, cfg *config.Config) error {
+	acked, err := q.pusher.PushConfig(ctx, q.target.URL, q.target.InternalKey, q.target.Namespace, cfg)
+
+	q.mu.Lock()
+	now := time.Now()
+	q.status.Attempts++
+	q.status.LastAttemptAt = &now
+	if err == nil && acked != cfg.Version {
+		err = errors.New(errors.ErrCodeExternalService, fmt.Sprintf("worker acknowledged version %d instead of %d", acked, cfg.Version))
+	}
+	if err != nil {
+		q.status.LastError = err.Error()
+	} else {
+		q.status.AppliedVersion = acked
+	}
+	q.mu.Unlock()
+
+	return err
+}
+
+func (q *Queue) recordRetry(version int, wait time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.status.PendingVersion != version {
+		return
+	}
+
+	next := time.Now().Add(wait)
+	q.status.NextAttemptAt = &next
+	q.persist()
+}
+
+// persist saves the status; the caller holds q.mu
+func (q *Queue) persist() {
+	status := q.status
+	if err := q.state.SaveDelivery(&status); err != nil {
+		log.Printf("[Delivery] Warning: %v", err)
+	}
+}
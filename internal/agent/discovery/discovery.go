@@ -0,0 +1,290 @@
+// Package discovery tracks the workers that announced themselves to the agent,
+// over the agent API or through announcement files, and drops the ones that
+// stop answering their health check.
+package discovery
+
+import (
+	"context"
+	"distributed_system/internal/config"
+	"distributed_system/internal/domain/agents"
+	"distributed_system/internal/domain/worker"
+	"distributed_system/pkg/errors"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sink starts and stops delivering to discovered workers
+type Sink interface {
+	AddWorker(w config.Worker)
+	RemoveWorker(url string)
+}
+
+// Worker is a discovered worker as reported by the agent API
+type Worker struct {
+	worker.Announcement
+	// Source is "api" or the announcement file
+	Source        string     `json:"source"`
+	DiscoveredAt  time.Time  `json:"discovered_at"`
+	LastHealthyAt *time.Time `json:"last_healthy_at,omitempty"`
+	Failures      int        `json:"failures"`
+}
+
+const sourceAPI = "api"
+
+type Discovery struct {
+	cfg        config.Discovery
+	sink       Sink
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	workers map[string]*Worker
+	// dropped remembers when a dead worker was dropped, so its announcement
+	// file is only picked up again once the worker rewrites it
+	dropped map[string]time.Time
+}
+
+func NewDiscovery(cfg config.Discovery, sink Sink, httpClient *http.Client) *Discovery {
+	return &Discovery{
+		cfg:        cfg,
+		sink:       sink,
+		httpClient: httpClient,
+		workers:    map[string]*Worker{},
+		dropped:    map[string]time.Time{},
+	}
+}
+
+// Announce adds a worker, or updates it when it announced itself before
+func (d *Discovery) Announce(announcement worker.Announcement) error {
+	return d.announce(announcement, sourceAPI)
+}
+
+func (d *Discovery) announce(announcement worker.Announcement, source string) error {
+	if announcement.URL == "" || announcement.HealthURL == "" {
+		return errors.InvalidInput("url and health_url are required")
+	}
+	if len(announcement.Namespaces) == 0 {
+		announcement.Namespaces = []string{agents.DefaultNamespace}
+	}
+
+	d.mu.Lock()
+	existing, known := d.workers[announcement.URL]
+	if known && sameAnnouncement(existing.Announcement, announcement) {
+		existing.Source = source
+		d.mu.Unlock()
+		return nil
+	}
+
+	d.workers[announcement.URL] = &Worker{
+		Announcement: announcement,
+		Source:       source,
+		DiscoveredAt: time.Now(),
+	}
+	delete(d.dropped, announcement.URL)
+	d.mu.Unlock()
+
+	if !known {
+		log.Printf("[Discovery] Worker %s announced itself (namespaces: %v)", announcement.URL, announcement.Namespaces)
+	}
+	d.sink.AddWorker(config.Worker{
+		URL:         announcement.URL,
+		InternalKey: announcement.InternalKey,
+		Namespaces:  announcement.Namespaces,
+	})
+	return nil
+}
+
+// Withdraw removes a worker that is shutting down
+func (d *Discovery) Withdraw(url string) {
+	d.mu.Lock()
+	_, known := d.workers[url]
+	delete(d.workers, url)
+	d.mu.Unlock()
+
+	if known {
+		log.Printf("[Discovery] Worker %s withdrew", url)
+		d.sink.RemoveWorker(url)
+	}
+}
+
+// Workers lists the discovered workers ordered by URL
+func (d *Discovery) Workers() []Worker {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	workers := make([]Worker, 0, len(d.workers))
+	for _, w := range d.workers {
+		copied := *w
+		copied.InternalKey = ""
+		workers = append(workers, copied)
+	}
+
+	sort.Slice(workers, func(i, j int) bool { return workers[i].URL < workers[j].URL })
+	return workers
+}
+
+// Run scans the announcement directory and health-checks the discovered
+// workers every health interval until ctx is cancelled
+func (d *Discovery) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		d.scanDir()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		d.checkHealth(ctx)
+	}
+}
+
+// scanDir picks up new or rewritten announcement files and withdraws the
+// workers whose file is gone
+func (d *Discovery) scanDir() {
+	if d.cfg.Dir == "" {
+		return
+	}
+
+	paths, err := filepath.Glob(filepath.Join(d.cfg.Dir, "*.json"))
+	if err != nil {
+		log.Printf("[Discovery] Warning: %v", err)
+		return
+	}
+
+	present := map[string]bool{}
+	for _, path := range paths {
+		present[path] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[Discovery] Warning: failed to read %s: %v", path, err)
+			continue
+		}
+
+		var announcement worker.Announcement
+		if err := json.Unmarshal(data, &announcement); err != nil {
+			log.Printf("[Discovery] Warning: invalid announcement %s: %v", path, err)
+			continue
+		}
+
+		d.mu.Lock()
+		droppedAt, dropped := d.dropped[announcement.URL]
+		d.mu.Unlock()
+		if dropped && !info.ModTime().After(droppedAt) {
+			continue
+		}
+
+		if err := d.announce(announcement, path); err != nil {
+			log.Printf("[Discovery] Warning: invalid announcement %s: %v", path, err)
+		}
+	}
+
+	d.mu.Lock()
+	var gone []string
+	for url, w := range d.workers {
+		if w.Source != sourceAPI && !present[w.Source] {
+			gone = append(gone, url)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, url := range gone {
+		d.Withdraw(url)
+	}
+}
+
+// checkHealth calls every worker's health endpoint concurrently and drops
+// the ones that failed MaxFailures times in a row
+func (d *Discovery) checkHealth(ctx context.Context) {
+	d.mu.Lock()
+	targets := map[string]string{}
+	for url, w := range d.workers {
+		targets[url] = w.HealthURL
+	}
+	d.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for url, healthURL := range targets {
+		wg.Add(1)
+		go func(url, healthURL string) {
+			defer wg.Done()
+			d.recordHealth(url, d.healthy(ctx, healthURL))
+		}(url, healthURL)
+	}
+	wg.Wait()
+}
+
+func (d *Discovery) healthy(ctx context.Context, healthURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(errors.ErrCodeExternalService, http.StatusText(resp.StatusCode))
+	}
+	return nil
+}
+
+func (d *Discovery) recordHealth(url string, err error) {
+	d.mu.Lock()
+	w, ok := d.workers[url]
+	if !ok {
+		d.mu.Unlock()
+		return
+	}
+
+	if err == nil {
+		now := time.Now()
+		w.LastHealthyAt = &now
+		w.Failures = 0
+		d.mu.Unlock()
+		return
+	}
+
+	w.Failures++
+	log.Printf("[Discovery] Health check of %s failed (%d/%d): %v", url, w.Failures, d.cfg.MaxFailures, err)
+	if w.Failures < d.cfg.MaxFailures {
+		d.mu.Unlock()
+		return
+	}
+
+	delete(d.workers, url)
+	d.dropped[url] = time.Now()
+	d.mu.Unlock()
+
+	log.Printf("[Discovery] Dropping worker %s", url)
+	d.sink.RemoveWorker(url)
+}
+
+func sameAnnouncement(a, b worker.Announcement) bool {
+	if a.URL != b.URL || a.HealthURL != b.HealthURL || a.InternalKey != b.InternalKey || len(a.Namespaces) != len(b.Namespaces) {
+		return false
+	}
+	for i := range a.Namespaces {
+		if a.Namespaces[i] != b.Namespaces[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,284 @@
+package agent
+
+import (
+	"context"
+	domainAgents "distributed_system/internal/domain/agents"
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/pki"
+	"distributed_system/pkg/retry"
+	"fmt"
+	"log"
+	"net"
+	neturl "net/url"
+	"os"
+	"runtime"
+	"time"
+)
+
+// register loads the stored credential, enrolling with the registration
+// token from the config when there is none
+func (a *Agent) register(ctx context.Context) error {
+	credential, err := a.store.LoadCredential()
+	if err != nil {
+		return err
+	}
+
+	if credential == nil {
+		if err := a.enroll(ctx, a.cfg.Identity.InternalKey); err != nil {
+			return err
+		}
+		return a.trustConfigSigningKeys()
+	}
+
+	a.setCredential(credential)
+
+	// credentials stored before the controller handed out its signing key
+	// get it with a rotation
+	if !a.verifier.Enabled() && len(credential.ConfigSigningKeys) == 0 {
+		if err := a.rotateCredential(ctx, a.cfg.Retry); err != nil {
+			return fmt.Errorf("failed to get the config signing key: %w", err)
+		}
+	}
+
+	return a.trustConfigSigningKeys()
+}
+
+// trustConfigSigningKeys verifies configs with the keys the controller handed
+// out at enrollment, unless config_signing.trusted_keys lists keys already.
+// The keys are forwarded to the workers with every push.
+func (a *Agent) trustConfigSigningKeys() error {
+	if a.verifier.Enabled() {
+		a.worker.SetConfigSigningKeys(a.cfg.ConfigSigning.TrustedKeys)
+		return nil
+	}
+
+	a.credentialMu.RLock()
+	keys := a.credential.ConfigSigningKeys
+	a.credentialMu.RUnlock()
+
+	if len(keys) == 0 {
+		return fmt.Errorf("the controller sent no config signing key, set config_signing.trusted_keys")
+	}
+
+	verifier, err := crypto.NewEd25519Verifier(keys)
+	if err != nil {
+		return fmt.Errorf("invalid config signing key from the controller: %w", err)
+	}
+
+	a.verifier = verifier
+	a.worker.SetConfigSigningKeys(keys)
+	log.Printf("[Agent] Verifying configs with the signing key pinned at enrollment")
+	return nil
+}
+
+// Register enrolls the agent with a registration token. It fails when the
+// agent is registered already; Reset it first to enroll again.
+func (a *Agent) Register(ctx context.Context, token string) error {
+	credential, err := a.store.LoadCredential()
+	if err != nil {
+		return err
+	}
+	if credential != nil {
+		return fmt.Errorf("already registered as %s, reset the agent to enroll again", credential.AgentID)
+	}
+
+	return a.enroll(ctx, token)
+}
+
+// Reset forgets the credential, client certificate, cached configs and
+// delivery state, so the next start enrolls as a new agent
+func (a *Agent) Reset() error {
+	if err := a.store.Reset(); err != nil {
+		return err
+	}
+	if err := a.tls.Reset(); err != nil {
+		return err
+	}
+
+	a.credentialMu.Lock()
+	a.credential = nil
+	a.credentialMu.Unlock()
+	return nil
+}
+
+// enroll registers with the controller using token and stores the credential
+func (a *Agent) enroll(ctx context.Context, token string) error {
+	input := a.hostMetadata()
+
+	// the controller sets the certificate subject to the agent id, so the CSR
+	// subject here is only informational
+	var keyPEM []byte
+	if a.tls != nil {
+		var csrPEM []byte
+		var err error
+		keyPEM, csrPEM, err = pki.GenerateKeyAndCSR(input.Hostname)
+		if err != nil {
+			return fmt.Errorf("error generating CSR: %w", err)
+		}
+		input.CSR = string(csrPEM)
+	}
+
+	var credential *domainAgents.Credential
+	err := a.call(ctx, a.cfg.Retry, "Registration", func(ctx context.Context) error {
+		var err error
+		credential, err = a.controller.Register(ctx, token, input)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if a.tls != nil && credential.Certificate != nil {
+		if err := a.tls.Store(credential.Certificate, keyPEM); err != nil {
+			return err
+		}
+	}
+	credential.Certificate = nil
+
+	if err := a.store.SaveCredential(credential); err != nil {
+		return err
+	}
+
+	log.Printf("[Agent] Registered as %s", credential.AgentID)
+	a.setCredential(credential)
+	return nil
+}
+
+// maintainCredentials rotates the credential and renews the client
+// certificate when they are due; failures are retried on the next fetch.
+// Namespaces fetch concurrently, so only one of them renews at a time.
+func (a *Agent) maintainCredentials(ctx context.Context, policy retry.Policy) {
+	a.maintainMu.Lock()
+	defer a.maintainMu.Unlock()
+
+	if a.credentialNeedsRotation() {
+		if err := a.rotateCredential(ctx, policy); err != nil {
+			log.Printf("[Agent] Error rotating credential: %v", err)
+		}
+	}
+
+	if a.tls.NeedsCertificate() {
+		if err := a.renewCertificate(ctx, policy); err != nil {
+			log.Printf("[Agent] Error renewing client certificate: %v", err)
+		} else {
+			log.Println("[Agent] Client certificate renewed")
+		}
+	}
+}
+
+// credentialNeedsRotation is true for legacy credentials, when the controller
+// asked for it, and for credentials inside the configured rotation window
+func (a *Agent) credentialNeedsRotation() bool {
+	a.credentialMu.RLock()
+	defer a.credentialMu.RUnlock()
+
+	if a.credential.ExpiresAt.IsZero() || a.controller.RotateRequested() {
+		return true
+	}
+
+	return time.Until(a.credential.ExpiresAt) < a.cfg.Controller.CredentialRotateBefore
+}
+
+func (a *Agent) rotateCredential(ctx context.Context, policy retry.Policy) error {
+	var credential *domainAgents.Credential
+	err := a.call(ctx, policy, "Credential rotation", func(ctx context.Context) error {
+		var err error
+		credential, err = a.controller.RotateCredential(ctx, a.token())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// the signing key pinned at enrollment is kept; a new controller key has
+	// to be listed in config_signing.trusted_keys
+	a.credentialMu.RLock()
+	if len(a.credential.ConfigSigningKeys) > 0 {
+		credential.ConfigSigningKeys = a.credential.ConfigSigningKeys
+	}
+	a.credentialMu.RUnlock()
+
+	if err := a.store.SaveCredential(credential); err != nil {
+		return err
+	}
+
+	a.setCredential(credential)
+
+	log.Printf("[Agent] Credential rotated to generation %d, expires %s",
+		credential.Generation, credential.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// renewCertificate sends a CSR for a fresh key and swaps in the new certificate
+func (a *Agent) renewCertificate(ctx context.Context, policy retry.Policy) error {
+	a.credentialMu.RLock()
+	agentID := a.credential.AgentID
+	a.credentialMu.RUnlock()
+
+	keyPEM, csrPEM, err := pki.GenerateKeyAndCSR(agentID)
+	if err != nil {
+		return fmt.Errorf("error generating CSR: %w", err)
+	}
+
+	var certificate *domainAgents.Certificate
+	err = a.call(ctx, policy, "Certificate renewal", func(ctx context.Context) error {
+		var err error
+		certificate, err = a.controller.RenewCertificate(ctx, a.token(), csrPEM)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.tls.Store(certificate, keyPEM)
+}
+
+// hostMetadata collects what the controller needs to map this agent back to a machine
+func (a *Agent) hostMetadata() *domainAgents.InputRegister {
+	hostname := a.cfg.Identity.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+
+	workerURLs := []string{}
+	for _, worker := range a.cfg.WorkerTargets() {
+		workerURLs = append(workerURLs, worker.URL)
+	}
+
+	return &domainAgents.InputRegister{
+		Hostname:     hostname,
+		IPAddress:    outboundIP(a.cfg.Controller.URL),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		AgentVersion: a.version,
+		WorkerURLs:   workerURLs,
+		Labels:       a.cfg.Identity.Labels,
+	}
+}
+
+// outboundIP returns the local address used to reach the controller, or "" to let
+// the controller fall back to the address it sees
+func outboundIP(controllerURL string) string {
+	parsed, err := neturl.Parse(controllerURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host = net.JoinHostPort(parsed.Hostname(), "80")
+	}
+
+	conn, err := net.DialTimeout("udp", host, 2*time.Second)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return ""
+	}
+
+	return addr.IP.String()
+}
@@ -2,7 +2,6 @@ package scheduler
 
 import (
 	"context"
-	"distributed_system/internal/agent/client"
 	"distributed_system/internal/domain/config"
 	"fmt"
 	"log"
@@ -10,31 +9,35 @@ import (
 	"time"
 )
 
+// Fetcher returns the controller's current config
+type Fetcher interface {
+	FetchConfig(ctx context.Context) (*config.Config, error)
+}
+
 // ConfigScheduler handles periodic config fetching from the controller
 type ConfigScheduler struct {
-	controllerURL string
-	token         string
-	client        *client.ConfigClient
-	currentConfig *config.Config
-	mu            sync.RWMutex
-	ticker        *time.Ticker
-	tickerMu      sync.Mutex
-	stopCh        chan struct{}
-	running       bool
-	onConfigUpdate func(*config.Config)
+	fetcher        Fetcher
+	currentConfig  *config.Config
+	mu             sync.RWMutex
+	ticker         *time.Ticker
+	tickerMu       sync.Mutex
+	stopCh         chan struct{}
+	running        bool
+	onConfigUpdate func(context.Context, *config.Config)
 }
 
-func NewConfigScheduler(controllerURL, token string, onConfigUpdate func(*config.Config)) *ConfigScheduler {
+// NewConfigScheduler creates a scheduler that calls onConfigUpdate whenever a
+// newer config version is fetched
+func NewConfigScheduler(fetcher Fetcher, onConfigUpdate func(context.Context, *config.Config)) *ConfigScheduler {
 	return &ConfigScheduler{
-		controllerURL:  controllerURL,
-		token:          token,
-		client:         client.NewConfigClient(controllerURL),
+		fetcher:        fetcher,
 		stopCh:         make(chan struct{}),
 		onConfigUpdate: onConfigUpdate,
 	}
 }
 
-// Start begins the periodic config fetching with initial interval
+// Start begins the periodic config fetching with initial interval. Without a
+// current config it fetches one right away.
 func (s *ConfigScheduler) Start(ctx context.Context, initialInterval int) {
 	s.tickerMu.Lock()
 	if s.running {
@@ -43,18 +46,15 @@ func (s *ConfigScheduler) Start(ctx context.Context, initialInterval int) {
 		return
 	}
 	s.running = true
+	s.ticker = time.NewTicker(time.Duration(initialInterval) * time.Second)
 	s.tickerMu.Unlock()
 
-	interval := time.Duration(initialInterval) * time.Second
-	s.tickerMu.Lock()
-	s.ticker = time.NewTicker(interval)
-	s.tickerMu.Unlock()
-
-	log.Printf("[ConfigScheduler] Started. Checking config every %d seconds from %s",
-		initialInterval, s.controllerURL)
+	log.Printf("[ConfigScheduler] Started. Checking config every %d seconds", initialInterval)
 
 	// Initial fetch
-	s.fetchConfig(ctx)
+	if s.GetConfig() == nil {
+		s.fetchConfig(ctx)
+	}
 
 	// Periodic fetch
 	go func() {
@@ -114,12 +114,11 @@ func (s *ConfigScheduler) updateInterval(newInterval int) {
 	s.tickerMu.Lock()
 	defer s.tickerMu.Unlock()
 
-	if s.ticker != nil {
-		s.ticker.Stop()
+	if s.ticker == nil {
+		return
 	}
 
-	interval := time.Duration(newInterval) * time.Second
-	s.ticker = time.NewTicker(interval)
+	s.ticker.Reset(time.Duration(newInterval) * time.Second)
 
 	log.Printf("[ConfigScheduler] Interval updated to %d seconds", newInterval)
 }
@@ -128,60 +127,68 @@ func (s *ConfigScheduler) updateInterval(newInterval int) {
 func (s *ConfigScheduler) fetchConfig(ctx context.Context) {
 	log.Printf("[ConfigScheduler] Fetching config from controller...")
 
-	newConfig, err := s.client.GetLatestConfig(ctx, s.token)
+	newConfig, err := s.fetcher.FetchConfig(ctx)
 	if err != nil {
 		log.Printf("[ConfigScheduler] Error fetching config: %v", err)
 		return
 	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	previous := s.currentConfig
 
 	// Check if config has changed
-	if s.currentConfig == nil || newConfig.Version > s.currentConfig.Version {
-		log.Printf("[ConfigScheduler] New config received! Version: %d (was %d)",
-			newConfig.Version, func() int {
-				if s.currentConfig != nil {
-					return s.currentConfig.Version
-				}
-				return 0
-			}())
-
-		// Check if pooling interval changed
-		if s.currentConfig != nil && s.currentConfig.PoolingInterval != newConfig.PoolingInterval {
-			log.Printf("[ConfigScheduler] Pooling interval changed: %d -> %d",
-				s.currentConfig.PoolingInterval, newConfig.PoolingInterval)
-			s.updateInterval(newConfig.PoolingInterval)
-		}
+	if previous != nil && newConfig.Version <= previous.Version {
+		s.mu.Unlock()
+		log.Printf("[ConfigScheduler] Config unchanged. Version: %d", newConfig.Version)
+		return
+	}
 
-		s.currentConfig = newConfig
+	s.currentConfig = newConfig
+	s.mu.Unlock()
 
-		// Call callback if config updated
-		if s.onConfigUpdate != nil {
-			s.onConfigUpdate(newConfig)
-		}
+	if previous != nil {
+		log.Printf("[ConfigScheduler] New config received! Version: %d (was %d)", newConfig.Version, previous.Version)
 	} else {
-		log.Printf("[ConfigScheduler] Config unchanged. Version: %d", newConfig.Version)
+		log.Printf("[ConfigScheduler] New config received! Version: %d", newConfig.Version)
+	}
+
+	s.applyInterval(previous, newConfig)
+
+	// Call callback if config updated
+	if s.onConfigUpdate != nil {
+		s.onConfigUpdate(ctx, newConfig)
+	}
+}
+
+// applyInterval follows a changed pooling interval
+func (s *ConfigScheduler) applyInterval(previous, next *config.Config) {
+	if previous != nil && previous.PoolingInterval != next.PoolingInterval {
+		log.Printf("[ConfigScheduler] Pooling interval changed: %d -> %d",
+			previous.PoolingInterval, next.PoolingInterval)
+		s.updateInterval(next.PoolingInterval)
 	}
 }
 
-// ForceFetch forces an immediate config fetch
+// ForceFetch forces an immediate config fetch; the callback runs even when
+// the version did not change
 func (s *ConfigScheduler) ForceFetch(ctx context.Context) error {
 	log.Println("[ConfigScheduler] Force fetching config...")
 
-	newConfig, err := s.client.GetLatestConfig(ctx, s.token)
+	newConfig, err := s.fetcher.FetchConfig(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to force fetch config: %w", err)
 	}
 
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
+	previous := s.currentConfig
 	s.currentConfig = newConfig
+	s.mu.Unlock()
+
+	s.applyInterval(previous, newConfig)
 
 	// Call callback if config updated
 	if s.onConfigUpdate != nil {
-		s.onConfigUpdate(newConfig)
+		s.onConfigUpdate(ctx, newConfig)
 	}
 
 	return nil
@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"distributed_system/internal/agent/api"
+	domainConfig "distributed_system/internal/domain/config"
+	"distributed_system/pkg/errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The agent implements api.Runtime, so its API can report and drive it
+
+// Status reports what the agent is doing: the controller it talks to, the
+// config of every namespace and the delivery state of every worker
+func (a *Agent) Status() api.Status {
+	status := api.Status{
+		AgentVersion: a.version,
+		StartedAt:    a.startedAt,
+		Deliveries:   a.Deliveries(),
+	}
+
+	a.credentialMu.RLock()
+	if a.credential != nil {
+		status.AgentID = a.credential.AgentID
+	}
+	a.credentialMu.RUnlock()
+
+	a.contactMu.Lock()
+	status.Controller = a.contact
+	a.contactMu.Unlock()
+	status.Controller.URL = a.cfg.Controller.URL
+
+	for _, ns := range a.namespaceList() {
+		status.Namespaces = append(status.Namespaces, ns.status())
+	}
+
+	if a.discovery != nil {
+		status.Discovered = a.discovery.Workers()
+	}
+
+	return status
+}
+
+// Config returns the namespace's current config, or the one cached on disk
+// while the namespace has not bootstrapped yet
+func (a *Agent) Config(namespace string) (*domainConfig.Config, error) {
+	if ns := a.namespace(namespace); ns != nil {
+		if cfg := ns.scheduler.GetConfig(); cfg != nil {
+			return cfg, nil
+		}
+	}
+
+	cached, err := a.store.LoadConfig(namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to read cached config")
+	}
+	if cached == nil {
+		return nil, errors.NotFound("config")
+	}
+	return cached, nil
+}
+
+// Sync fetches the config of one or all namespaces now and pushes it to
+// their workers, even when the version did not change
+func (a *Agent) Sync(ctx context.Context, namespace string) error {
+	namespaces := a.namespaceList()
+	if namespace != "" {
+		ns := a.namespace(namespace)
+		if ns == nil {
+			return errors.NotFound("namespace")
+		}
+		namespaces = []*namespaceSync{ns}
+	}
+
+	for _, ns := range namespaces {
+		if err := ns.scheduler.ForceFetch(ctx); err != nil {
+			return errors.Wrap(err, errors.ErrCodeExternalService, fmt.Sprintf("failed to sync namespace %s", ns.namespace))
+		}
+	}
+	return nil
+}
+
+// Ready is nil once the agent is enrolled and every namespace has a config
+// to serve its workers
+func (a *Agent) Ready() error {
+	a.credentialMu.RLock()
+	enrolled := a.credential != nil
+	a.credentialMu.RUnlock()
+	if !enrolled {
+		return fmt.Errorf("not registered with the controller")
+	}
+
+	a.workersMu.Lock()
+	started := a.ctx != nil
+	a.workersMu.Unlock()
+	if !started {
+		return fmt.Errorf("starting")
+	}
+
+	for _, ns := range a.namespaceList() {
+		if ns.scheduler.GetConfig() == nil {
+			return fmt.Errorf("namespace %s has no config yet", ns.namespace)
+		}
+	}
+	return nil
+}
+
+// recordControllerContact keeps the outcome of the latest config fetch
+func (a *Agent) recordControllerContact(at time.Time, err error) {
+	a.contactMu.Lock()
+	defer a.contactMu.Unlock()
+
+	a.contact.LastFetchAt = &at
+	a.contact.Reachable = err == nil
+	if err != nil {
+		a.contact.LastError = err.Error()
+	} else {
+		a.contact.LastSuccessAt = &at
+		a.contact.LastError = ""
+	}
+}
+
+func (a *Agent) namespace(name string) *namespaceSync {
+	a.workersMu.Lock()
+	defer a.workersMu.Unlock()
+	return a.namespaces[name]
+}
+
+// namespaceList returns the namespaces ordered by name
+func (a *Agent) namespaceList() []*namespaceSync {
+	a.workersMu.Lock()
+	defer a.workersMu.Unlock()
+
+	namespaces := make([]*namespaceSync, 0, len(a.namespaces))
+	for _, ns := range a.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].namespace < namespaces[j].namespace })
+	return namespaces
+}
+
+func (ns *namespaceSync) status() api.NamespaceStatus {
+	status := api.NamespaceStatus{
+		Namespace: ns.namespace,
+		Workers:   len(ns.queueList()),
+	}
+
+	if cfg := ns.scheduler.GetConfig(); cfg != nil {
+		status.Version = cfg.Version
+		status.ConfigURL = cfg.ConfigURL
+	}
+
+	ns.statusMu.Lock()
+	status.LastFetchAt = ns.lastFetchAt
+	status.LastSuccessAt = ns.lastSuccessAt
+	status.LastError = ns.lastError
+	ns.statusMu.Unlock()
+
+	return status
+}
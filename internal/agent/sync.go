@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"distributed_system/internal/agent/delivery"
+	"distributed_system/internal/agent/scheduler"
+	domainConfig "distributed_system/internal/domain/config"
+	"distributed_system/pkg/retry"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// namespaceSync keeps the workers following one namespace on its latest
+// config: its own scheduler polls the controller and every new version is
+// fanned out to the namespace's delivery queues, which push concurrently
+type namespaceSync struct {
+	agent     *Agent
+	namespace string
+	scheduler *scheduler.ConfigScheduler
+	// cancel stops the bootstrap and the sync loop
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	queues []*delivery.Queue
+
+	// statusMu guards the outcome of the latest fetch, for the agent API
+	statusMu      sync.Mutex
+	lastFetchAt   *time.Time
+	lastSuccessAt *time.Time
+	lastError     string
+}
+
+// addQueue adds a worker's queue and hands it the current config, if the
+// namespace has one already
+func (ns *namespaceSync) addQueue(queue *delivery.Queue) {
+	ns.mu.Lock()
+	ns.queues = append(ns.queues, queue)
+	ns.mu.Unlock()
+
+	if current := ns.scheduler.GetConfig(); current != nil {
+		queue.Enqueue(current)
+	}
+}
+
+// removeQueue returns the number of queues left
+func (ns *namespaceSync) removeQueue(queue *delivery.Queue) int {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	for i, q := range ns.queues {
+		if q == queue {
+			ns.queues = append(ns.queues[:i], ns.queues[i+1:]...)
+			break
+		}
+	}
+	return len(ns.queues)
+}
+
+func (ns *namespaceSync) queueList() []*delivery.Queue {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return append([]*delivery.Queue{}, ns.queues...)
+}
+
+func (ns *namespaceSync) stop() {
+	ns.cancel()
+	ns.scheduler.Stop()
+}
+
+// FetchConfig renews credentials when due, then fetches, verifies and
+// persists the controller's current config. It implements scheduler.Fetcher.
+func (ns *namespaceSync) FetchConfig(ctx context.Context) (*domainConfig.Config, error) {
+	return ns.fetchConfig(ctx, ns.agent.cfg.Retry)
+}
+
+func (ns *namespaceSync) fetchConfig(ctx context.Context, policy retry.Policy) (*domainConfig.Config, error) {
+	a := ns.agent
+	a.maintainCredentials(ctx, policy)
+
+	var latest *domainConfig.Config
+	err := a.call(ctx, policy, fmt.Sprintf("Fetching config of namespace %s", ns.namespace), func(ctx context.Context) error {
+		var err error
+		latest, err = a.controller.GetLatestConfig(ctx, a.token(), ns.namespace)
+		return err
+	})
+	ns.recordFetch(err)
+	if err != nil {
+		return nil, err
+	}
+
+	if !latest.InNamespace(ns.namespace) {
+		return nil, fmt.Errorf("controller returned config version %d of namespace %q for namespace %q", latest.Version, latest.Namespace, ns.namespace)
+	}
+
+	// never persist or forward a config the controller did not sign
+	if err := latest.VerifySignature(a.verifier); err != nil {
+		return nil, fmt.Errorf("config version %d failed signature verification: %w", latest.Version, err)
+	}
+
+	current := ns.scheduler.GetConfig()
+	if current == nil || latest.Version > current.Version {
+		if err := a.store.SaveConfig(ns.namespace, latest); err != nil {
+			log.Printf("[Agent] Warning: %v", err)
+		}
+	} else {
+		for _, queue := range ns.queueList() {
+			queue.Verify(ctx, current)
+		}
+	}
+
+	return latest, nil
+}
+
+// recordFetch keeps the outcome of a fetch for the agent API
+func (ns *namespaceSync) recordFetch(err error) {
+	now := time.Now()
+
+	ns.statusMu.Lock()
+	ns.lastFetchAt = &now
+	if err != nil {
+		ns.lastError = err.Error()
+	} else {
+		ns.lastSuccessAt = &now
+		ns.lastError = ""
+	}
+	ns.statusMu.Unlock()
+
+	ns.agent.recordControllerContact(now, err)
+}
+
+// onConfigUpdate is called by the scheduler for every new config version
+func (ns *namespaceSync) onConfigUpdate(ctx context.Context, cfg *domainConfig.Config) {
+	for _, queue := range ns.queueList() {
+		queue.Enqueue(cfg)
+	}
+}
+
+// bootstrap fetches the initial config, queues it for the workers and starts
+// the sync loop. When the controller is unreachable the workers get the
+// last-known-good config from disk and the sync loop keeps trying the
+// controller; without one it waits for the controller.
+func (ns *namespaceSync) bootstrap(ctx context.Context) error {
+	log.Printf("[Agent] Fetching initial config of namespace %s from Controller...", ns.namespace)
+	// a single attempt, so a controller outage does not delay the cached config
+	initialConfig, err := ns.fetchConfig(ctx, retry.Policy{})
+	if err != nil {
+		log.Printf("[Agent] Failed to fetch initial config of namespace %s: %v", ns.namespace, err)
+
+		initialConfig = ns.lastKnownGood()
+		if initialConfig == nil {
+			initialConfig, err = ns.waitForController(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Printf("[Agent] Initial config of namespace %s: Version=%d, URL=%s", ns.namespace, initialConfig.Version, initialConfig.ConfigURL)
+
+	// set first, so a worker added meanwhile gets the config from addQueue
+	// if it is not in the list handed the config here
+	ns.scheduler.SetInitialConfig(ctx, initialConfig)
+	ns.onConfigUpdate(ctx, initialConfig)
+
+	return nil
+}
+
+// lastKnownGood returns the config saved on a previous run, or nil when there
+// is none or it no longer verifies
+func (ns *namespaceSync) lastKnownGood() *domainConfig.Config {
+	a := ns.agent
+
+	cached, err := a.store.LoadConfig(ns.namespace)
+	if err != nil {
+		log.Printf("[Agent] Warning: %v", err)
+		return nil
+	}
+	if cached == nil || !cached.InNamespace(ns.namespace) {
+		return nil
+	}
+
+	if err := cached.VerifySignature(a.verifier); err != nil {
+		log.Printf("[Agent] Warning: cached config version %d of namespace %s failed signature verification: %v", cached.Version, ns.namespace, err)
+		return nil
+	}
+
+	log.Printf("[Agent] Starting namespace %s from last-known-good config (version %d), controller is retried in the background", ns.namespace, cached.Version)
+	return cached
+}
+
+// waitForController retries the initial fetch until it succeeds, fails for
+// good or ctx is cancelled
+func (ns *namespaceSync) waitForController(ctx context.Context) (*domainConfig.Config, error) {
+	log.Printf("[Agent] No cached config of namespace %s, waiting for Controller...", ns.namespace)
+	return ns.fetchConfig(ctx, ns.agent.cfg.Retry.WithoutLimit())
+}
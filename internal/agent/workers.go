@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"distributed_system/internal/agent/delivery"
+	"distributed_system/internal/agent/scheduler"
+	"distributed_system/internal/config"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// workerTarget is a worker the agent delivers to, with one queue per
+// namespace it follows
+type workerTarget struct {
+	worker config.Worker
+	// static workers come from the agent config; discovery never removes them
+	static bool
+	queues map[string]*delivery.Queue
+	// cancel stops the queues when the worker is removed
+	cancel context.CancelFunc
+}
+
+// AddWorker starts delivering to a discovered worker, replacing it when it
+// was discovered before with different settings. It implements
+// discovery.Sink.
+func (a *Agent) AddWorker(w config.Worker) {
+	a.addWorker(w, false)
+}
+
+// RemoveWorker stops delivering to a discovered worker. It implements
+// discovery.Sink.
+func (a *Agent) RemoveWorker(url string) {
+	a.workersMu.Lock()
+	defer a.workersMu.Unlock()
+
+	if target, ok := a.workers[url]; ok && !target.static {
+		a.removeWorkerLocked(target)
+	}
+}
+
+func (a *Agent) addWorker(w config.Worker, static bool) {
+	a.workersMu.Lock()
+	defer a.workersMu.Unlock()
+
+	if existing, ok := a.workers[w.URL]; ok {
+		if existing.static && !static {
+			log.Printf("[Agent] Ignoring announcement of %s, it is configured statically", w.URL)
+			return
+		}
+		a.removeWorkerLocked(existing)
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	target := &workerTarget{
+		worker: w,
+		static: static,
+		queues: map[string]*delivery.Queue{},
+		cancel: cancel,
+	}
+
+	for _, namespace := range w.Namespaces {
+		queue := delivery.NewQueue(delivery.Target{
+			URL:         w.URL,
+			InternalKey: w.InternalKey,
+			Namespace:   namespace,
+		}, a.worker, a.cfg.Retry, a.store)
+		target.queues[namespace] = queue
+
+		go queue.Run(ctx)
+		a.namespaceLocked(namespace).addQueue(queue)
+	}
+
+	a.workers[w.URL] = target
+}
+
+// removeWorkerLocked stops the worker's queues and the namespaces no worker
+// follows anymore; the caller holds workersMu
+func (a *Agent) removeWorkerLocked(target *workerTarget) {
+	target.cancel()
+	delete(a.workers, target.worker.URL)
+
+	for namespace, queue := range target.queues {
+		ns := a.namespaces[namespace]
+		if ns.removeQueue(queue) == 0 {
+			ns.stop()
+			delete(a.namespaces, namespace)
+		}
+	}
+}
+
+// namespaceLocked returns the sync loop of a namespace, starting it for the
+// first worker that follows it; the caller holds workersMu
+func (a *Agent) namespaceLocked(namespace string) *namespaceSync {
+	if ns, ok := a.namespaces[namespace]; ok {
+		return ns
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	ns := &namespaceSync{agent: a, namespace: namespace, cancel: cancel}
+	ns.scheduler = scheduler.NewConfigScheduler(ns, ns.onConfigUpdate)
+	a.namespaces[namespace] = ns
+
+	go func() {
+		if err := ns.bootstrap(ctx); err != nil && ctx.Err() == nil {
+			a.fail(fmt.Errorf("namespace %s: %w", namespace, err))
+		}
+	}()
+
+	return ns
+}
+
+// Deliveries returns the delivery state of every worker and namespace
+func (a *Agent) Deliveries() []delivery.Status {
+	a.workersMu.Lock()
+	defer a.workersMu.Unlock()
+
+	var statuses []delivery.Status
+	for _, target := range a.workers {
+		for _, queue := range target.queues {
+			statuses = append(statuses, queue.Status())
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key() < statuses[j].Key() })
+	return statuses
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"distributed_system/pkg/crypto"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -11,6 +13,8 @@ type Config struct {
 	Database DatabaseConfig `mapstructure:"database"`
 	Redis    RedisConfig    `mapstructure:"redis"`
 	Security SecurityConfig `mapstructure:"security"`
+	TLS      TLSConfig      `mapstructure:"tls"`
+	Webhooks WebhookConfig  `mapstructure:"webhooks"`
 }
 
 type ServerConfig struct {
@@ -21,6 +25,34 @@ type ControllerConfig struct {
 	Port int `mapstructure:"port"`
 }
 
+// TLSConfig turns the controller into a small CA. Agents enroll with a CSR and
+// must present the issued client certificate on agent endpoints.
+type TLSConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	CACertFile string `mapstructure:"ca_cert_file"`
+	CAKeyFile  string `mapstructure:"ca_key_file"`
+	// CertFile/KeyFile are the serving certificate; issued from the CA when empty
+	CertFile string   `mapstructure:"cert_file"`
+	KeyFile  string   `mapstructure:"key_file"`
+	Hosts    []string `mapstructure:"hosts"`
+	// ClientCertTTL is the lifetime of agent client certificates
+	ClientCertTTL time.Duration `mapstructure:"client_cert_ttl"`
+}
+
+// WebhookConfig controls delivery of outbound webhook events. A failed
+// delivery is retried after BackoffBase, doubling up to BackoffMax, until
+// MaxAttempts is reached.
+type WebhookConfig struct {
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	BackoffBase  time.Duration `mapstructure:"backoff_base"`
+	BackoffMax   time.Duration `mapstructure:"backoff_max"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// AgentOfflineAfter is how long an agent may go without polling before
+	// agent.offline is sent
+	AgentOfflineAfter time.Duration `mapstructure:"agent_offline_after"`
+}
+
 type DatabaseConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
@@ -44,9 +76,110 @@ func (r *RedisConfig) Addr() string {
 }
 
 type SecurityConfig struct {
-	AgentSecret string `mapstructure:"agent_secret"`
 	JWTSecret   string `mapstructure:"jwt_secret"`
 	AgentSig    string `mapstructure:"agent_signature"`
+	// AccessTokenTTL and RefreshTokenTTL bound admin sessions: access tokens are
+	// short-lived JWTs, refresh tokens live in Redis and are single use
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"`
+	// TOTPIssuer is the account issuer shown in authenticator apps
+	TOTPIssuer string `mapstructure:"totp_issuer"`
+	// AgentCredentialTTL is how long an issued agent credential stays valid
+	AgentCredentialTTL time.Duration `mapstructure:"agent_credential_ttl"`
+	// LegacyAgentTokensUntil (RFC3339) ends the window in which pre-JWT agent
+	// credentials are still accepted; empty leaves the window open
+	LegacyAgentTokensUntil string `mapstructure:"legacy_agent_tokens_until"`
+
+	// ConfigSigningKeyFile holds the Ed25519 key that signs published configs;
+	// created on first start when missing
+	ConfigSigningKeyFile string `mapstructure:"config_signing_key_file"`
+
+	JWTKeys   KeyringConfig `mapstructure:"jwt_keys"`
+	AgentKeys KeyringConfig `mapstructure:"agent_keys"`
+
+	Login LoginProtectionConfig `mapstructure:"login"`
+	OIDC  OIDCConfig            `mapstructure:"oidc"`
+
+	// built from the settings above by Load
+	JWTKeyring               *crypto.Keyring `mapstructure:"-"`
+	AgentKeyring             *crypto.Keyring `mapstructure:"-"`
+	LegacyAgentTokenDeadline time.Time       `mapstructure:"-"`
+}
+
+// OIDCConfig enables admin single sign-on through an OpenID Connect provider
+// (authorization code flow with PKCE)
+type OIDCConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
+	// EmailClaim names the claim matched against local admin emails
+	EmailClaim string `mapstructure:"email_claim"`
+	// AutoProvision creates a local admin on first sign-in when a rule grants a role
+	AutoProvision bool `mapstructure:"auto_provision"`
+	// RoleRules are checked in order and the first match sets the admin's role;
+	// DefaultRole applies when none matches, empty denies the sign-in
+	RoleRules   []OIDCRoleRule `mapstructure:"role_rules"`
+	DefaultRole string         `mapstructure:"default_role"`
+}
+
+// OIDCRoleRule matches when the claim equals Value or, for list claims such
+// as groups, contains it
+type OIDCRoleRule struct {
+	Claim string `mapstructure:"claim"`
+	Value string `mapstructure:"value"`
+	Role  string `mapstructure:"role"`
+}
+
+// LoginProtectionConfig limits failed logins. Reaching a limit inside Window
+// locks the IP or account out for LockoutBase, doubling on every repeat
+// lockout within a day up to LockoutMax.
+type LoginProtectionConfig struct {
+	MaxAccountAttempts int64         `mapstructure:"max_account_attempts"`
+	MaxIPAttempts      int64         `mapstructure:"max_ip_attempts"`
+	Window             time.Duration `mapstructure:"window"`
+	LockoutBase        time.Duration `mapstructure:"lockout_base"`
+	LockoutMax         time.Duration `mapstructure:"lockout_max"`
+}
+
+// KeyringConfig lists signing keys by id. Active signs new tokens, every other
+// key is still accepted for verification until it is removed.
+type KeyringConfig struct {
+	Active string             `mapstructure:"active"`
+	Keys   []SigningKeyConfig `mapstructure:"keys"`
+}
+
+type SigningKeyConfig struct {
+	ID     string `mapstructure:"id"`
+	Secret string `mapstructure:"secret"`
+}
+
+// buildKeyring merges the keyring with the legacy single secret. The legacy
+// secret stays valid for verification and signs only when no keys are listed.
+func buildKeyring(k KeyringConfig, legacySecret string) (*crypto.Keyring, error) {
+	keys := map[string]string{}
+	if legacySecret != "" {
+		keys[crypto.LegacyKeyID] = legacySecret
+	}
+
+	for _, key := range k.Keys {
+		if key.ID == crypto.LegacyKeyID {
+			return nil, fmt.Errorf("signing key id cannot be empty")
+		}
+		if _, ok := keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key id %q", key.ID)
+		}
+		keys[key.ID] = key.Secret
+	}
+
+	active := k.Active
+	if active == "" && len(k.Keys) > 0 {
+		return nil, fmt.Errorf("an active signing key is required when keys are listed")
+	}
+
+	return crypto.NewKeyring(active, keys)
 }
 
 func Load(path string) (*Config, error) {
@@ -56,6 +189,29 @@ func Load(path string) (*Config, error) {
 	v.SetConfigType("yaml")
 	v.AddConfigPath(path)
 
+	v.SetDefault("security.access_token_ttl", "15m")
+	v.SetDefault("security.refresh_token_ttl", "168h")
+	v.SetDefault("security.totp_issuer", "Distributed System")
+	v.SetDefault("security.agent_credential_ttl", "720h")
+	v.SetDefault("security.login.max_account_attempts", 5)
+	v.SetDefault("security.login.max_ip_attempts", 20)
+	v.SetDefault("security.login.window", "15m")
+	v.SetDefault("security.login.lockout_base", "1m")
+	v.SetDefault("security.login.lockout_max", "1h")
+	v.SetDefault("security.oidc.scopes", []string{"openid", "email", "profile"})
+	v.SetDefault("security.oidc.email_claim", "email")
+	v.SetDefault("security.config_signing_key_file", "certs/config-signing-key.pem")
+	v.SetDefault("webhooks.max_attempts", 8)
+	v.SetDefault("webhooks.backoff_base", "30s")
+	v.SetDefault("webhooks.backoff_max", "1h")
+	v.SetDefault("webhooks.timeout", "10s")
+	v.SetDefault("webhooks.poll_interval", "5s")
+	v.SetDefault("webhooks.agent_offline_after", "5m")
+	v.SetDefault("tls.ca_cert_file", "certs/ca.pem")
+	v.SetDefault("tls.ca_key_file", "certs/ca-key.pem")
+	v.SetDefault("tls.hosts", []string{"localhost", "127.0.0.1"})
+	v.SetDefault("tls.client_cert_ttl", "168h")
+
 	// support ENV override (optional)
 	v.AutomaticEnv()
 
@@ -68,6 +224,38 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	jwtKeyring, err := buildKeyring(cfg.Security.JWTKeys, cfg.Security.JWTSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt_keys: %w", err)
+	}
+	cfg.Security.JWTKeyring = jwtKeyring
+
+	agentKeyring, err := buildKeyring(cfg.Security.AgentKeys, cfg.Security.AgentSig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent_keys: %w", err)
+	}
+	cfg.Security.AgentKeyring = agentKeyring
+
+	if cfg.Security.LegacyAgentTokensUntil != "" {
+		deadline, err := time.Parse(time.RFC3339, cfg.Security.LegacyAgentTokensUntil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid legacy_agent_tokens_until: %w", err)
+		}
+		cfg.Security.LegacyAgentTokenDeadline = deadline
+	}
+
+	if cfg.Security.OIDC.Enabled {
+		oidc := cfg.Security.OIDC
+		if oidc.Issuer == "" || oidc.ClientID == "" || oidc.RedirectURL == "" {
+			return nil, fmt.Errorf("invalid oidc: issuer, client_id and redirect_url are required")
+		}
+		for _, rule := range oidc.RoleRules {
+			if rule.Claim == "" || rule.Role == "" {
+				return nil, fmt.Errorf("invalid oidc role rule: claim and role are required")
+			}
+		}
+	}
+
 	return &cfg, nil
 }
 
@@ -1,28 +1,147 @@
 package config
 
 import (
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/retry"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type IdentityConfig struct {
-	InternalKey string `mapstructure:"internal_key"`
+	InternalKey string            `mapstructure:"internal_key"`
+	Hostname    string            `mapstructure:"hostname"`
+	Labels      map[string]string `mapstructure:"labels"`
 }
 
 type Controller struct {
 	URL string `mapstructure:"url"`
+	// CredentialRotateBefore is how long before expiry the agent rotates its credential
+	CredentialRotateBefore time.Duration `mapstructure:"credential_rotate_before"`
 }
 
 type Worker struct {
 	URL         string `mapstructure:"url"`
 	InternalKey string `mapstructure:"internal_key"`
+	// Namespaces are the config namespaces pushed to the worker, ["default"]
+	// when empty
+	Namespaces []string `mapstructure:"namespaces"`
+}
+
+// AgentAPI is the agent's local HTTP API
+type AgentAPI struct {
+	// Listen is the API address; keep it on loopback unless workers on other
+	// hosts announce themselves
+	Listen string `mapstructure:"listen"`
+}
+
+// Discovery lets workers come and go without config edits: they announce
+// themselves on the agent API (POST /workers) or drop a JSON file in Dir, and
+// the agent drops those failing their /health check MaxFailures times in a row
+type Discovery struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Token must be presented by workers announcing over the API; without one
+	// only workers on the same host can announce
+	Token string `mapstructure:"token"`
+	// Dir holds one announcement file (*.json) per worker, empty disables it
+	Dir            string        `mapstructure:"dir"`
+	HealthInterval time.Duration `mapstructure:"health_interval"`
+	MaxFailures    int           `mapstructure:"max_failures"`
+}
+
+// AgentState is where the agent keeps its credential, configs, delivery state
+// and TLS material
+type AgentState struct {
+	// Dir is created with mode 0700 and locked while an agent uses it;
+	// AGENT_STATE_DIR overrides it, see defaultStateDir
+	Dir        string          `mapstructure:"dir"`
+	Encryption StateEncryption `mapstructure:"encryption"`
+}
+
+// StateEncryption encrypts the state files with a key derived from KeyFile or
+// Passphrase; the state is stored in plain text when both are empty
+type StateEncryption struct {
+	// KeyFile is a machine-local secret, created with random content when it
+	// does not exist. Keep it outside the state directory.
+	KeyFile string `mapstructure:"key_file"`
+	// Passphrase is used instead of KeyFile when set; pass it through
+	// AGENT_STATE_PASSPHRASE rather than the config file
+	Passphrase string `mapstructure:"passphrase"`
+}
+
+// Enabled reports whether a key source is configured
+func (e StateEncryption) Enabled() bool {
+	return e.KeyFile != "" || e.Passphrase != ""
+}
+
+// AgentTLS enables mTLS enrollment: the agent sends a CSR when registering and
+// presents the issued certificate to the controller and the worker
+type AgentTLS struct {
+	Enabled bool `mapstructure:"enabled"`
+	// CAFile is the controller CA trusted before enrollment returns one
+	CAFile string `mapstructure:"ca_file"`
+}
+
+// ConfigSigning lists the base64 Ed25519 public keys whose config signatures
+// are accepted. Keep the old key listed while the controller rolls to a new one.
+type ConfigSigning struct {
+	TrustedKeys []string `mapstructure:"trusted_keys"`
+}
+
+// Verifier builds the verifier for the trusted keys; it is disabled when the
+// list is empty
+func (c ConfigSigning) Verifier() (*crypto.Ed25519Verifier, error) {
+	return crypto.NewEd25519Verifier(c.TrustedKeys)
 }
 
 type ConfigAgents struct {
 	Identity   IdentityConfig `mapstructure:"identity"`
 	Controller Controller     `mapstructure:"controller"`
+	// Worker is the single-worker form of Workers, kept for existing configs
 	Worker     Worker         `mapstructure:"worker"`
+	Workers    []Worker       `mapstructure:"workers"`
+	Discovery  Discovery      `mapstructure:"discovery"`
+	API        AgentAPI       `mapstructure:"api"`
+	State      AgentState     `mapstructure:"state"`
+	TLS        AgentTLS       `mapstructure:"tls"`
+	ConfigSigning ConfigSigning `mapstructure:"config_signing"`
+	// Retry applies to every call to the controller and the worker
+	Retry retry.Policy `mapstructure:"retry"`
+}
+
+// WorkerTargets lists every configured worker, with the default namespace
+// filled in
+func (c *ConfigAgents) WorkerTargets() []Worker {
+	workers := append([]Worker{}, c.Workers...)
+	if c.Worker.URL != "" {
+		workers = append(workers, c.Worker)
+	}
+
+	for i := range workers {
+		if len(workers[i].Namespaces) == 0 {
+			workers[i].Namespaces = []string{"default"}
+		}
+	}
+
+	return workers
+}
+
+// defaultStateDir is /var/lib/agent for root and $XDG_STATE_HOME/agent
+// (~/.local/state/agent) for other users
+func defaultStateDir() string {
+	if os.Geteuid() == 0 {
+		return "/var/lib/agent"
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "agent")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "agent")
+	}
+	return "/var/lib/agent"
 }
 
 func LoadConfigAgents(path string) (*ConfigAgents, error) {
@@ -32,7 +151,20 @@ func LoadConfigAgents(path string) (*ConfigAgents, error) {
 	v.SetConfigType("yaml")
 	v.AddConfigPath(path)
 
+	v.SetDefault("controller.credential_rotate_before", "72h")
+	v.SetDefault("retry.initial_interval", "1s")
+	v.SetDefault("retry.max_interval", "30s")
+	v.SetDefault("retry.multiplier", 2)
+	v.SetDefault("retry.jitter", 0.2)
+	v.SetDefault("retry.max_elapsed", "2m")
+	v.SetDefault("api.listen", "127.0.0.1:8081")
+	v.SetDefault("state.dir", defaultStateDir())
+	v.SetDefault("discovery.health_interval", "15s")
+	v.SetDefault("discovery.max_failures", 3)
+
 	v.AutomaticEnv()
+	v.BindEnv("state.dir", "AGENT_STATE_DIR")
+	v.BindEnv("state.encryption.passphrase", "AGENT_STATE_PASSPHRASE")
 
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read config: %w", err)
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,6 +14,47 @@ type WorkerConfig struct {
 	Auth struct {
 		InternalKey string `mapstructure:"internal_key"`
 	} `mapstructure:"auth"`
+	TLS WorkerTLSConfig `mapstructure:"tls"`
+	ConfigSigning WorkerConfigSigning `mapstructure:"config_signing"`
+	Discovery WorkerDiscovery `mapstructure:"discovery"`
+}
+
+// WorkerConfigSigning lists the keys pushed configs are verified with; without
+// them the worker pins the keys its agent forwards. AllowUnsigned accepts
+// unverified configs while no keys are listed, for development only
+type WorkerConfigSigning struct {
+	ConfigSigning `mapstructure:",squash"`
+	AllowUnsigned bool `mapstructure:"allow_unsigned"`
+}
+
+// WorkerDiscovery announces the worker to its local agent, through the agent
+// API (AgentURL), a file in the agent's discovery directory (Dir) or both, so
+// the agent needs no static entry for it
+type WorkerDiscovery struct {
+	AgentURL string `mapstructure:"agent_url"`
+	// Token is the agent's discovery.token
+	Token string `mapstructure:"token"`
+	Dir   string `mapstructure:"dir"`
+	// AdvertiseURL is the base URL the agent reaches this worker at
+	AdvertiseURL string        `mapstructure:"advertise_url"`
+	Namespaces   []string      `mapstructure:"namespaces"`
+	Interval     time.Duration `mapstructure:"interval"`
+}
+
+// Enabled is true when the worker has somewhere to announce itself
+func (d WorkerDiscovery) Enabled() bool {
+	return d.AgentURL != "" || d.Dir != ""
+}
+
+// WorkerTLSConfig serves the worker over TLS and requires agents on /private
+// to present a client certificate signed by CAFile (the controller CA)
+type WorkerTLSConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	CAFile   string `mapstructure:"ca_file"`
+	// InsecureSkipVerify disables certificate checks on outbound task requests
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
 }
 
 func LoadWorkerConfig(configPath string) (*WorkerConfig, error) {
@@ -22,8 +64,11 @@ func LoadWorkerConfig(configPath string) (*WorkerConfig, error) {
 	viper.AddConfigPath(".")
 
 	viper.SetDefault("server.port", 8082)
+	viper.SetDefault("discovery.interval", "30s")
 
 	viper.AutomaticEnv()
+	viper.BindEnv("config_signing.trusted_keys", "WORKER_CONFIG_SIGNING_KEYS")
+	viper.BindEnv("config_signing.allow_unsigned", "WORKER_ALLOW_UNSIGNED_CONFIGS")
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("failed to read worker config file: %w", err)
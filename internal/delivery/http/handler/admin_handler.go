@@ -3,6 +3,7 @@ package handler
 import (
 	"distributed_system/internal/domain/admin"
 	"distributed_system/pkg/response"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,11 +24,317 @@ func (h *AdminHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.usecase.Login(c.Request.Context(), &input)
+	input.IP = c.ClientIP()
+
+	result, err := h.usecase.Login(c.Request.Context(), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func (h *AdminHandler) LoginMFA(c *gin.Context) {
+	var input admin.InputLoginMFA
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	input.IP = c.ClientIP()
+
+	result, err := h.usecase.LoginMFA(c.Request.Context(), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// OIDCLogin redirects the browser to the identity provider
+func (h *AdminHandler) OIDCLogin(c *gin.Context) {
+	url, err := h.usecase.StartOIDCLogin(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, url)
+}
+
+func (h *AdminHandler) OIDCCallback(c *gin.Context) {
+	// the provider reports a cancelled or refused sign-in as ?error=
+	if providerError := c.Query("error"); providerError != "" {
+		response.Unauthorized(c, "Single sign-on failed: "+providerError)
+		return
+	}
+
+	var input admin.InputOIDCCallback
+
+	if err := c.ShouldBindQuery(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	input.IP = c.ClientIP()
+
+	result, err := h.usecase.LoginOIDC(c.Request.Context(), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func (h *AdminHandler) Refresh(c *gin.Context) {
+	var input admin.InputRefresh
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	result, err := h.usecase.Refresh(c.Request.Context(), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+func (h *AdminHandler) Logout(c *gin.Context) {
+	var input admin.InputLogout
+
+	// the body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.BindingError(c, err)
+			return
+		}
+	}
+
+	claims := c.MustGet("admin_claims").(*admin.Claims)
+	if err := h.usecase.Logout(c.Request.Context(), claims, &input); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AdminHandler) Create(c *gin.Context) {
+	var input admin.InputCreateAdmin
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	account, err := h.usecase.Create(c.Request.Context(), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, account)
+}
+
+func (h *AdminHandler) GetAll(c *gin.Context) {
+	admins, err := h.usecase.GetAll(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, admins)
+}
+
+func (h *AdminHandler) GetById(c *gin.Context) {
+	account, err := h.usecase.GetById(c.Request.Context(), c.Param("uuid"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, account)
+}
+
+func (h *AdminHandler) Me(c *gin.Context) {
+	account := c.MustGet("admin").(*admin.Admin)
+
+	response.Success(c, gin.H{
+		"admin":       account,
+		"permissions": account.Role.Permissions(),
+	})
+}
+
+func (h *AdminHandler) Disable(c *gin.Context) {
+	if err := h.usecase.Disable(c.Request.Context(), c.GetString("admin_id"), c.Param("uuid")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AdminHandler) Enable(c *gin.Context) {
+	if err := h.usecase.Enable(c.Request.Context(), c.Param("uuid")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AdminHandler) Delete(c *gin.Context) {
+	if err := h.usecase.Delete(c.Request.Context(), c.GetString("admin_id"), c.Param("uuid")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AdminHandler) ChangePassword(c *gin.Context) {
+	var input admin.InputChangePassword
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	if err := h.usecase.ChangePassword(c.Request.Context(), c.GetString("admin_id"), &input); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AdminHandler) UpdateRole(c *gin.Context) {
+	var input admin.InputUpdateRole
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	account, err := h.usecase.UpdateRole(c.Request.Context(), c.GetString("admin_id"), c.Param("uuid"), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, account)
+}
+
+func (h *AdminHandler) GetLockouts(c *gin.Context) {
+	lockouts, err := h.usecase.GetLockouts(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, lockouts)
+}
+
+func (h *AdminHandler) EnrollTOTP(c *gin.Context) {
+	enrollment, err := h.usecase.EnrollTOTP(c.Request.Context(), c.GetString("admin_id"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, enrollment)
+}
+
+func (h *AdminHandler) ConfirmTOTP(c *gin.Context) {
+	var input admin.InputTOTPCode
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	codes, err := h.usecase.ConfirmTOTP(c.Request.Context(), c.GetString("admin_id"), &input)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, token)
-}
\ No newline at end of file
+	response.Success(c, codes)
+}
+
+func (h *AdminHandler) DisableTOTP(c *gin.Context) {
+	var input admin.InputDisableTOTP
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	if err := h.usecase.DisableTOTP(c.Request.Context(), c.GetString("admin_id"), &input); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AdminHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var input admin.InputTOTPCode
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	codes, err := h.usecase.RegenerateRecoveryCodes(c.Request.Context(), c.GetString("admin_id"), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, codes)
+}
+
+func (h *AdminHandler) CreateAPIKey(c *gin.Context) {
+	var input admin.InputCreateAPIKey
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	key, err := h.usecase.CreateAPIKey(c.Request.Context(), c.GetString("admin_id"), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, key)
+}
+
+func (h *AdminHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.usecase.GetAPIKeys(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, keys)
+}
+
+func (h *AdminHandler) RevokeAPIKey(c *gin.Context) {
+	if err := h.usecase.RevokeAPIKey(c.Request.Context(), c.Param("uuid")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
@@ -16,21 +16,133 @@ func NewAgentsHandler(agentUsecase agents.Usecase) *AgentsHandler {
 }
 
 func (h *AgentsHandler) Register(c *gin.Context) {
-	token, err := h.agentUsecase.Create(c.Request.Context())
+	var input agents.InputRegister
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	// fall back to the address the request came from when the agent can't tell
+	if input.IPAddress == "" {
+		input.IPAddress = c.ClientIP()
+	}
+
+	registrationToken, ok := c.MustGet("registration_token").(*agents.RegistrationToken)
+	if !ok {
+		response.Unauthorized(c, "Unauthorized")
+		return
+	}
+
+	credential, err := h.agentUsecase.Create(c.Request.Context(), &input, registrationToken)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, credential)
+}
+
+func (h *AgentsHandler) RotateCredential(c *gin.Context) {
+	agent, ok := c.MustGet("agent").(*agents.Agent)
+	if !ok {
+		response.Unauthorized(c, "Unauthorized")
+		return
+	}
+
+	credential, err := h.agentUsecase.RotateCredential(c.Request.Context(), agent)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, token)
+	response.Success(c, credential)
 }
 
-func (h *AgentsHandler) GenerateRegistrationConfifg(c *gin.Context) {
-	token, err := h.agentUsecase.CreateRegistrationToken(c.Request.Context())
+func (h *AgentsHandler) RenewCertificate(c *gin.Context) {
+	agent, ok := c.MustGet("agent").(*agents.Agent)
+	if !ok {
+		response.Unauthorized(c, "Unauthorized")
+		return
+	}
+
+	var input agents.InputRenewCertificate
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	certificate, err := h.agentUsecase.RenewCertificate(c.Request.Context(), agent, &input)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.Success(c, token)
-}
\ No newline at end of file
+	response.Success(c, certificate)
+}
+
+func (h *AgentsHandler) RevokeCredential(c *gin.Context) {
+	if err := h.agentUsecase.RevokeCredential(c.Request.Context(), c.Param("uuid")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AgentsHandler) CreateRegistrationToken(c *gin.Context) {
+	var input agents.InputRegistrationToken
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	token, err := h.agentUsecase.CreateRegistrationToken(c.Request.Context(), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, token)
+}
+
+func (h *AgentsHandler) GetRegistrationTokens(c *gin.Context) {
+	tokens, err := h.agentUsecase.GetRegistrationTokens(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, tokens)
+}
+
+func (h *AgentsHandler) RevokeRegistrationToken(c *gin.Context) {
+	if err := h.agentUsecase.RevokeRegistrationToken(c.Request.Context(), c.Param("uuid")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *AgentsHandler) GetAll(c *gin.Context) {
+	list, err := h.agentUsecase.GetAll(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, list)
+}
+
+func (h *AgentsHandler) GetById(c *gin.Context) {
+	agent, err := h.agentUsecase.GetById(c.Request.Context(), c.Param("uuid"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, agent)
+}
@@ -17,7 +17,7 @@ func NewConfigHandler(config config.Usecase) *ConfigHandler {
 }
 
 func (h *ConfigHandler) GetLatestConfigAdmin(c *gin.Context) {
-	config, err := h.config.GetLatestConfig(context.Background(), nil)
+	config, err := h.config.GetLatestConfig(context.Background(), namespaceOf(c), nil)
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -40,7 +40,7 @@ func (h *ConfigHandler) GetLatestConfigModel(c *gin.Context) {
 		return
 	}
 	
-	config, err := h.config.GetLatestConfig(context.Background(), &uuidStr)
+	config, err := h.config.GetLatestConfig(context.Background(), namespaceOf(c), &uuidStr)
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -57,7 +57,7 @@ func (h *ConfigHandler) Create(gin *gin.Context) {
 		return
 	}
 
-	config, err := h.config.Create(context.Background(), &input)
+	config, err := h.config.Create(context.Background(), namespaceOf(gin), &input)
 	if err != nil {
 		response.Error(gin, err)
 		return
@@ -74,10 +74,34 @@ func (h *ConfigHandler) Update(gin *gin.Context) {
 		return
 	}
 
-	if err := h.config.Update(context.Background(), &input); err != nil {
+	if err := h.config.Update(context.Background(), namespaceOf(gin), &input); err != nil {
 		response.Error(gin, err)
 		return
 	}
 
 	response.Success(gin, nil)
-}
\ No newline at end of file
+}
+
+// Rollback publishes the settings of an older version again as a new version
+func (h *ConfigHandler) Rollback(gin *gin.Context) {
+	var input config.SaveRollback
+
+	if err := gin.ShouldBindJSON(&input); err != nil {
+		response.BindingError(gin, err)
+		return
+	}
+
+	config, err := h.config.Rollback(context.Background(), namespaceOf(gin), &input)
+	if err != nil {
+		response.Error(gin, err)
+		return
+	}
+
+	response.Success(gin, config)
+}
+
+// namespaceOf is the config namespace a request acts on, as checked by
+// RequirePermission or AgentNamespaceValidation
+func namespaceOf(c *gin.Context) string {
+	return c.GetString("namespace")
+}
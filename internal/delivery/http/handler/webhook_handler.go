@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"distributed_system/internal/domain/webhook"
+	"distributed_system/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	usecase webhook.Usecase
+}
+
+func NewWebhookHandler(usecase webhook.Usecase) *WebhookHandler {
+	return &WebhookHandler{usecase: usecase}
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	var input webhook.InputCreateWebhook
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	hook, err := h.usecase.Create(c.Request.Context(), c.GetString("admin_id"), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Created(c, hook)
+}
+
+func (h *WebhookHandler) GetAll(c *gin.Context) {
+	hooks, err := h.usecase.GetAll(c.Request.Context())
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, hooks)
+}
+
+func (h *WebhookHandler) GetById(c *gin.Context) {
+	hook, err := h.usecase.GetById(c.Request.Context(), c.Param("uuid"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, hook)
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	var input webhook.InputUpdateWebhook
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BindingError(c, err)
+		return
+	}
+
+	hook, err := h.usecase.Update(c.Request.Context(), c.Param("uuid"), &input)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, hook)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	if err := h.usecase.Delete(c.Request.Context(), c.Param("uuid")); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+}
+
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	deliveries, err := h.usecase.GetDeliveries(c.Request.Context(), c.Param("uuid"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, deliveries)
+}
+
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	delivery, err := h.usecase.Redeliver(c.Request.Context(), c.Param("uuid"), c.Param("delivery"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, delivery)
+}
+
+func (h *WebhookHandler) Ping(c *gin.Context) {
+	delivery, err := h.usecase.Ping(c.Request.Context(), c.Param("uuid"))
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Success(c, delivery)
+}
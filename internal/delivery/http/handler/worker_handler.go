@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"distributed_system/internal/domain/agents"
 	"distributed_system/internal/domain/worker"
 	"distributed_system/pkg/response"
 	"log"
@@ -21,7 +22,7 @@ func NewWorkerHandler(usecase worker.Usecase) *WorkerHandler {
 }
 
 func (h *WorkerHandler) Hit(c *gin.Context) {
-	resp, err := h.usecase.Hit(c.Request.Context())
+	resp, err := h.usecase.Hit(c.Request.Context(), c.DefaultQuery("namespace", agents.DefaultNamespace))
 	if err != nil {
 		response.Error(c, err)
 		return
@@ -38,16 +39,26 @@ func (h *WorkerHandler) UpdateConfig(c *gin.Context) {
 		return
 	}
 
-	log.Printf("[Worker] Received config update from Agent: Version=%d, URL=%s",
-		req.Version, req.ConfigURL)
+	if req.Namespace == "" {
+		req.Namespace = agents.DefaultNamespace
+	}
+
+	log.Printf("[Worker] Received config update from Agent: Namespace=%s, Version=%d, URL=%s",
+		req.Namespace, req.Version, req.ConfigURL)
 
 	if err := h.usecase.UpdateConfig(c.Request.Context(), req); err != nil {
 		response.Error(c, err)
 		return
 	}
 
+	// the version is the agent's acknowledgement that this exact config applied
 	response.Success(c, gin.H{
 		"message": "Configuration updated successfully",
-		"version": req.Version,
+		"version": h.usecase.AppliedVersion(c.Request.Context(), req.Namespace),
 	})
 }
+
+func (h *WorkerHandler) AppliedVersion(c *gin.Context) {
+	namespace := c.DefaultQuery("namespace", agents.DefaultNamespace)
+	response.Success(c, gin.H{"version": h.usecase.AppliedVersion(c.Request.Context(), namespace)})
+}
@@ -3,17 +3,16 @@ package middleware
 import (
 	"distributed_system/internal/config"
 	"distributed_system/internal/domain/admin"
-	"distributed_system/pkg/crypto"
+	"distributed_system/internal/domain/agents"
 	"distributed_system/pkg/response"
 	"strings"
 
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func ValidationRegistrationAgent(cfg *config.Config) gin.HandlerFunc {
+func ValidationRegistrationAgent(agentUsecase agents.Usecase) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,17 +23,19 @@ func ValidationRegistrationAgent(cfg *config.Config) gin.HandlerFunc {
 
 		token := strings.SplitN(authHeader, " ", 2)[1] 
 
-		if err := bcrypt.CompareHashAndPassword([]byte(token), []byte(cfg.Security.AgentSecret)); err != nil {
+		registrationToken, err := agentUsecase.ValidateRegistrationToken(c.Request.Context(), token)
+		if err != nil {
 			response.Unauthorized(c, "Unauthorized")
 			c.Abort()
 			return
 		}
 
+		c.Set("registration_token", registrationToken)
 		c.Next()
 	}
 }
 
-func InternalGetConfigVaidation(cfg *config.Config) gin.HandlerFunc {
+func InternalGetConfigVaidation(agentUsecase agents.Usecase) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -45,25 +46,81 @@ func InternalGetConfigVaidation(cfg *config.Config) gin.HandlerFunc {
 
 		token := strings.SplitN(authHeader, " ", 2)[1] 
 
-		isValid, uuid, err := crypto.Verify(token, cfg.Security.AgentSig); 
+		agent, claims, err := agentUsecase.ValidateCredential(c.Request.Context(), token)
 		if err != nil {
 			response.Unauthorized(c, "Unauthorized")
 			c.Abort()
 			return
 		}
 
-		if !isValid {
-			response.Unauthorized(c, "Unauthorized")
+		// ask agents still on a pre-JWT credential to rotate it
+		if claims.Legacy {
+			c.Header("X-Credential-Rotate", "true")
+		}
+
+		c.Set("uuid", agent.UUID)
+		c.Set("agent", agent)
+		c.Set("credential_claims", claims)
+		c.Next()
+	}
+}
+
+// AgentNamespaceValidation rejects agents reading a namespace their credential
+// does not allow and passes the checked namespace on to the handler. Must run
+// after InternalGetConfigVaidation.
+func AgentNamespaceValidation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.Get("credential_claims")
+		namespace := c.DefaultQuery("namespace", agents.DefaultNamespace)
+		if !ok || !claims.(*agents.CredentialClaims).AllowsNamespace(namespace) {
+			response.Forbidden(c, "Forbidden")
 			c.Abort()
 			return
 		}
 
-		c.Set("uuid", uuid)
+		// the only namespace the handler may serve
+		c.Set("namespace", namespace)
 		c.Next()
 	}
 }
 
-func AdminValidation(cfg *config.Config) gin.HandlerFunc {
+// AgentCertificateValidation requires a client certificate from the controller CA
+// whose common name is the authenticated agent. Must run after InternalGetConfigVaidation.
+func AgentCertificateValidation(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.TLS.Enabled {
+			c.Next()
+			return
+		}
+
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			response.Unauthorized(c, "Client certificate required")
+			c.Abort()
+			return
+		}
+
+		leaf := c.Request.TLS.VerifiedChains[0][0]
+		if leaf.Subject.CommonName != c.GetString("uuid") {
+			response.Forbidden(c, "Forbidden")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func AdminValidation(cfg *config.Config, adminUsecase admin.Usecase) gin.HandlerFunc {
+	return adminValidation(cfg, adminUsecase, false)
+}
+
+// AdminPasswordChangeValidation is AdminValidation for the password change
+// endpoint, which must stay reachable while a password change is pending
+func AdminPasswordChangeValidation(cfg *config.Config, adminUsecase admin.Usecase) gin.HandlerFunc {
+	return adminValidation(cfg, adminUsecase, true)
+}
+
+func adminValidation(cfg *config.Config, adminUsecase admin.Usecase, allowPendingPasswordChange bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -74,25 +131,94 @@ func AdminValidation(cfg *config.Config) gin.HandlerFunc {
 
 		token := strings.SplitN(authHeader, " ", 2)[1]
 		
-		payload, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(cfg.Security.JWTSecret), nil
-		})
+		claims := &admin.Claims{}
+		payload, err := cfg.Security.JWTKeyring.ParseJWT(token, claims,
+			jwt.WithAudience(admin.TokenAudience),
+			jwt.WithExpirationRequired(),
+			jwt.WithIssuedAt(),
+		)
 		if err != nil || !payload.Valid  {
 			response.Unauthorized(c, "Unauthorized")
 			c.Abort()
 			return
 		}
 
-		claims, ok := payload.Claims.(*admin.Claims)
-		if !ok {
+		account, err := adminUsecase.Authenticate(c.Request.Context(), claims)
+		if err != nil {
+			response.Unauthorized(c, "Unauthorized")
+			c.Abort()
+			return
+		}
+
+		// a role change takes effect immediately; the admin logs in again
+		if !claims.Role.Valid() || claims.Role != account.Role {
+			response.Unauthorized(c, "Unauthorized")
+			c.Abort()
+			return
+		}
+
+		if account.MustChangePassword && !allowPendingPasswordChange {
+			response.Forbidden(c, "Password change required")
+			c.Abort()
+			return
+		}
+
+		c.Set("admin_id", account.UUID)
+		c.Set("admin_role", account.Role)
+		c.Set("admin_claims", claims)
+		c.Set("admin", account)
+
+		c.Next()
+	}
+}
+
+// AdminOrAPIKeyValidation accepts an API key (for automation) as well as an
+// admin access token. API keys are only checked against their own scopes by
+// RequirePermission.
+func AdminOrAPIKeyValidation(cfg *config.Config, adminUsecase admin.Usecase) gin.HandlerFunc {
+	validateAdmin := adminValidation(cfg, adminUsecase, false)
+
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[1], admin.APIKeyPrefix) {
+			validateAdmin(c)
+			return
+		}
+
+		key, err := adminUsecase.AuthenticateAPIKey(c.Request.Context(), parts[1])
+		if err != nil {
+			response.Unauthorized(c, "Unauthorized")
+			c.Abort()
+			return
+		}
+
+		c.Set("api_key", key)
+		c.Next()
+	}
+}
+
+// RequirePermission rejects admins whose role lacks the permission and API keys
+// without a scope for it on the requested namespace, then passes the checked
+// namespace on to the handler. Must run after AdminValidation or
+// AdminOrAPIKeyValidation.
+func RequirePermission(permission admin.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace := c.DefaultQuery("namespace", agents.DefaultNamespace)
+		c.Set("namespace", namespace)
+
+		if key, ok := c.Get("api_key"); ok {
+			if !key.(*admin.APIKey).Allows(permission, namespace) {
+				response.Forbidden(c, "Forbidden")
+				c.Abort()
+				return
+			}
+
 			c.Next()
 			return
 		}
 
-		if claims.Role != "admin" {
+		role, ok := c.Get("admin_role")
+		if !ok || !role.(admin.Role).Can(permission) {
 			response.Forbidden(c, "Forbidden")
 			c.Abort()
 			return
@@ -102,6 +228,25 @@ func AdminValidation(cfg *config.Config) gin.HandlerFunc {
 	}
 }
 
+// WorkerCertificateValidation requires a client certificate signed by the CA the
+// worker trusts
+func WorkerCertificateValidation(cfg *config.WorkerConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.TLS.Enabled {
+			c.Next()
+			return
+		}
+
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			response.Unauthorized(c, "Client certificate required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 func ValidationAgentWorker(cfg *config.WorkerConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -2,33 +2,317 @@ package admin
 
 import (
 	"context"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Role is an admin's access level; each role grants a fixed set of permissions
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleEditor   Role = "editor"
+	RoleApprover Role = "approver"
+	RoleOwner    Role = "owner"
+)
+
+type Permission string
+
+const (
+	PermReadConfigs  Permission = "configs:read"
+	PermWriteConfigs Permission = "configs:write"
+	PermManageAgents Permission = "agents:manage"
+	PermManageAdmins Permission = "admins:manage"
+	// PermManageWebhooks covers webhook endpoints and their delivery log
+	PermManageWebhooks Permission = "webhooks:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermReadConfigs},
+	RoleEditor:   {PermReadConfigs, PermWriteConfigs},
+	RoleApprover: {PermReadConfigs, PermWriteConfigs, PermManageAgents},
+	RoleOwner:    {PermReadConfigs, PermWriteConfigs, PermManageAgents, PermManageAdmins, PermManageWebhooks},
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants the permission
+func (r Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
 type Admin struct {
 	UUID      string `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
 	Email     string `json:"email" gorm:"column:email;type:text"`
-	Password  string `json:"password" gorm:"column:password;type:text"`
+	Password  string `json:"-" gorm:"column:password;type:text"`
+	Role      Role   `json:"role" gorm:"column:role;type:text"`
+	// MustChangePassword blocks everything but the password change endpoint
+	// until the account owner replaces the initial password
+	MustChangePassword bool       `json:"must_change_password" gorm:"column:must_change_password"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at" gorm:"column:password_changed_at"`
+	DisabledAt         *time.Time `json:"disabled_at" gorm:"column:disabled_at"`
+	// TOTPSecret is set at enrollment; 2FA is only enforced once TOTPEnabledAt
+	// is set by a verified code
+	TOTPSecret        string     `json:"-" gorm:"column:totp_secret;type:text"`
+	TOTPEnabledAt     *time.Time `json:"totp_enabled_at" gorm:"column:totp_enabled_at"`
+	TOTPLastStep      int64      `json:"-" gorm:"column:totp_last_step"`
+	TOTPRecoveryCodes []string   `json:"-" gorm:"column:totp_recovery_codes;type:jsonb;serializer:json"`
+	// OIDCIssuer/OIDCSubject link the admin to a single sign-on identity on first use
+	OIDCIssuer  string `json:"oidc_issuer,omitempty" gorm:"column:oidc_issuer;type:text"`
+	OIDCSubject string `json:"-" gorm:"column:oidc_subject;type:text"`
 	CreatedAt string `json:"created_at" gorm:"column:created_at;type:text"`
 }
 
 func (a *Admin) TableName() string { return "admin" }
 
+func (a *Admin) Disabled() bool {
+	return a.DisabledAt != nil
+}
+
+func (a *Admin) TOTPEnabled() bool {
+	return a.TOTPEnabledAt != nil
+}
+
 type Repostory interface {
 	GetByEmail(ctx context.Context, email string) (*Admin, error)
+	GetById(ctx context.Context, ID string) (*Admin, error)
+	GetByOIDCSubject(ctx context.Context, issuer, subject string) (*Admin, error)
+	GetAll(ctx context.Context) ([]Admin, error)
+	Create(ctx context.Context, account *Admin) error
+	Update(ctx context.Context, account *Admin) error
+	Delete(ctx context.Context, ID string) error
+	CountActiveByRole(ctx context.Context, role Role) (int64, error)
 }
 
 type InputLogin struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// IP is the client address, filled in by the handler
+	IP string `json:"-"`
+}
+
+const (
+	LockoutScopeIP      = "ip"
+	LockoutScopeAccount = "account"
+)
+
+// LoginLockout records an IP or account being locked out after repeated
+// failed logins
+type LoginLockout struct {
+	UUID        string    `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
+	Scope       string    `json:"scope" gorm:"column:scope;type:text"`
+	Subject     string    `json:"subject" gorm:"column:subject;type:text"`
+	IPAddress   string    `json:"ip_address" gorm:"column:ip_address;type:text"`
+	Attempts    int64     `json:"attempts" gorm:"column:attempts"`
+	LockedUntil time.Time `json:"locked_until" gorm:"column:locked_until"`
+	CreatedAt   string    `json:"created_at" gorm:"column:created_at;type:text"`
+}
+
+func (LoginLockout) TableName() string { return "login_lockouts" }
+
+type LoginLockoutRepository interface {
+	Create(ctx context.Context, lockout *LoginLockout) error
+	GetRecent(ctx context.Context, limit int) ([]LoginLockout, error)
+}
+
+// APIKeyPrefix starts every API key so it is told apart from an access token
+// and easy to spot in leaked logs
+const APIKeyPrefix = "dsk_"
+
+// AnyNamespace in an API key scope matches every namespace
+const AnyNamespace = "*"
+
+// APIKeyScope grants one permission on a set of namespaces
+type APIKeyScope struct {
+	Permission Permission `json:"permission" binding:"required,oneof=configs:read configs:write agents:manage"`
+	// Namespaces defaults to ["default"]; "*" matches every namespace
+	Namespaces []string `json:"namespaces"`
+}
+
+// APIKey is a credential for automation (CI/CD) acting on behalf of the admin
+// who created it. Only the SHA-256 of the key is stored; the plaintext is
+// returned once on creation.
+type APIKey struct {
+	UUID       string        `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
+	Name       string        `json:"name" gorm:"column:name;type:text"`
+	KeyPrefix  string        `json:"key_prefix" gorm:"column:key_prefix;type:text"`
+	KeyHash    string        `json:"-" gorm:"column:key_hash;type:text"`
+	Scopes     []APIKeyScope `json:"scopes" gorm:"column:scopes;type:jsonb;serializer:json"`
+	CreatedBy  string        `json:"created_by" gorm:"column:created_by;type:text"`
+	ExpiresAt  time.Time     `json:"expires_at" gorm:"column:expires_at"`
+	LastUsedAt *time.Time    `json:"last_used_at" gorm:"column:last_used_at"`
+	RevokedAt  *time.Time    `json:"revoked_at" gorm:"column:revoked_at"`
+	CreatedAt  string        `json:"created_at" gorm:"column:created_at;type:text"`
+}
+
+func (APIKey) TableName() string { return "api_keys" }
+
+// Usable reports whether the key is neither revoked nor expired at the given time
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && now.Before(k.ExpiresAt)
+}
+
+// Allows reports whether one of the key's scopes grants the permission on the namespace
+func (k *APIKey) Allows(permission Permission, namespace string) bool {
+	for _, scope := range k.Scopes {
+		if scope.Permission != permission {
+			continue
+		}
+		for _, ns := range scope.Namespaces {
+			if ns == AnyNamespace || ns == namespace {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetByHash(ctx context.Context, hash string) (*APIKey, error)
+	GetAll(ctx context.Context) ([]APIKey, error)
+	Revoke(ctx context.Context, ID string, now time.Time) error
+	// Touch sets last_used_at, at most once per interval to spare the database
+	Touch(ctx context.Context, ID string, now time.Time, interval time.Duration) error
+}
+
+type InputCreateAPIKey struct {
+	Name   string        `json:"name" binding:"required"`
+	Scopes []APIKeyScope `json:"scopes" binding:"required,min=1,dive"`
+	// ExpiresIn is the lifetime in seconds, defaults to 90 days
+	ExpiresIn int `json:"expires_in" binding:"omitempty,min=60"`
+}
+
+// CreatedAPIKey carries the plaintext key, which is never retrievable again
+type CreatedAPIKey struct {
+	Key string `json:"key"`
+	APIKey
+}
+
+// TokenAudience is the aud claim of admin access tokens
+const TokenAudience = "distributed-system-admin"
+
+// LoginResult is either a session or, for admins with 2FA, a challenge to
+// complete through LoginMFA
+type LoginResult struct {
+	Token              string     `json:"token,omitempty"`
+	ExpiresAt          *time.Time `json:"expires_at,omitempty"`
+	RefreshToken       string     `json:"refresh_token,omitempty"`
+	MustChangePassword bool       `json:"must_change_password"`
+	MFARequired        bool       `json:"mfa_required,omitempty"`
+	MFAToken           string     `json:"mfa_token,omitempty"`
+}
+
+// InputLoginMFA completes a 2FA login with either a TOTP code or a recovery code
+type InputLoginMFA struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" binding:"required_without=Code"`
+	IP           string `json:"-"`
+}
+
+// InputOIDCCallback is the query the provider redirects back with
+type InputOIDCCallback struct {
+	Code  string `form:"code" binding:"required"`
+	State string `form:"state" binding:"required"`
+	IP    string `form:"-"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// link authenticator apps import, usually as a QR code
+	URI string `json:"otpauth_uri"`
+}
+
+type InputTOTPCode struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type InputDisableTOTP struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type InputRefresh struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type InputLogout struct {
+	// RefreshToken ends the session for good; without it only the access token is revoked
+	RefreshToken string `json:"refresh_token"`
+}
+
+type InputCreateAdmin struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+	// Role defaults to viewer
+	Role Role `json:"role" binding:"omitempty,oneof=viewer editor approver owner"`
+}
+
+type InputUpdateRole struct {
+	Role Role `json:"role" binding:"required,oneof=viewer editor approver owner"`
+}
+
+type InputChangePassword struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
 }
 
 type Usecase interface {
-	Login(ctx context.Context, input *InputLogin) (string, error)
+	Login(ctx context.Context, input *InputLogin) (*LoginResult, error)
+	// Refresh trades a refresh token for a new access and refresh token pair
+	Refresh(ctx context.Context, input *InputRefresh) (*LoginResult, error)
+	LoginMFA(ctx context.Context, input *InputLoginMFA) (*LoginResult, error)
+	// StartOIDCLogin returns the provider URL that begins a single sign-on login
+	StartOIDCLogin(ctx context.Context) (string, error)
+	// LoginOIDC completes a single sign-on login; admins with 2FA still get a challenge
+	LoginOIDC(ctx context.Context, input *InputOIDCCallback) (*LoginResult, error)
+	Logout(ctx context.Context, claims *Claims, input *InputLogout) error
+	// Authenticate resolves the admin behind an access token and rejects
+	// revoked tokens and deleted or disabled accounts
+	Authenticate(ctx context.Context, claims *Claims) (*Admin, error)
+	Create(ctx context.Context, input *InputCreateAdmin) (*Admin, error)
+	GetAll(ctx context.Context) ([]Admin, error)
+	GetById(ctx context.Context, ID string) (*Admin, error)
+	Disable(ctx context.Context, actorID, ID string) error
+	Enable(ctx context.Context, ID string) error
+	Delete(ctx context.Context, actorID, ID string) error
+	UpdateRole(ctx context.Context, actorID, ID string, input *InputUpdateRole) (*Admin, error)
+	GetLockouts(ctx context.Context) ([]LoginLockout, error)
+	// EnrollTOTP starts (or restarts) enrollment; 2FA is not enforced until ConfirmTOTP
+	EnrollTOTP(ctx context.Context, ID string) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, ID string, input *InputTOTPCode) (*RecoveryCodes, error)
+	DisableTOTP(ctx context.Context, ID string, input *InputDisableTOTP) error
+	RegenerateRecoveryCodes(ctx context.Context, ID string, input *InputTOTPCode) (*RecoveryCodes, error)
+	ChangePassword(ctx context.Context, ID string, input *InputChangePassword) error
+
+	// CreateAPIKey issues a key whose scopes must be covered by the creator's role
+	CreateAPIKey(ctx context.Context, actorID string, input *InputCreateAPIKey) (*CreatedAPIKey, error)
+	GetAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, ID string) error
+	// AuthenticateAPIKey resolves a plaintext key and rejects revoked or expired
+	// keys and keys whose creator is gone, disabled or no longer holds the scopes
+	AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error)
 }
 
 type Claims struct {
-	Role string `json:"role"`
+	Role Role `json:"role"`
 	jwt.RegisteredClaims
-}
\ No newline at end of file
+}
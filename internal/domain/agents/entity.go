@@ -1,10 +1,42 @@
 package agents
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultNamespace is used when an agent or request names no namespace
+	DefaultNamespace = "default"
+
+	CredentialIssuer   = "distributed-system-controller"
+	CredentialAudience = "distributed-system-agent"
+)
 
 type Agent struct {
-	UUID        string `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
-	CreatedAt string `json:"created_at" gorm:"column:created_at;type:text"`
+	UUID         string            `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
+	Hostname     string            `json:"hostname" gorm:"column:hostname;type:text"`
+	IPAddress    string            `json:"ip_address" gorm:"column:ip_address;type:text"`
+	OS           string            `json:"os" gorm:"column:os;type:text"`
+	Arch         string            `json:"arch" gorm:"column:arch;type:text"`
+	AgentVersion string            `json:"agent_version" gorm:"column:agent_version;type:text"`
+	WorkerURLs   []string          `json:"worker_urls" gorm:"column:worker_urls;type:jsonb;serializer:json"`
+	Labels       map[string]string `json:"labels" gorm:"column:labels;type:jsonb;serializer:json"`
+	Environment  string            `json:"environment" gorm:"column:environment;type:text"`
+	Namespaces   []string          `json:"namespaces" gorm:"column:namespaces;type:jsonb;serializer:json"`
+	// RegistrationTokenID is the registration token the agent enrolled with
+	RegistrationTokenID *string `json:"registration_token_id" gorm:"column:registration_token_id;type:text"`
+	// CredentialGeneration is bumped on every rotation; older credentials stop working
+	CredentialGeneration int        `json:"credential_generation" gorm:"column:credential_generation;type:int"`
+	CredentialExpiresAt  *time.Time `json:"credential_expires_at" gorm:"column:credential_expires_at"`
+	CredentialRevokedAt  *time.Time `json:"credential_revoked_at" gorm:"column:credential_revoked_at"`
+	// LastSeenAt is the last config poll; OfflineAt is set once the agent has
+	// been silent for too long and cleared when it polls again
+	LastSeenAt *time.Time `json:"last_seen_at" gorm:"column:last_seen_at"`
+	OfflineAt  *time.Time `json:"offline_at" gorm:"column:offline_at"`
+	CreatedAt  string     `json:"created_at" gorm:"column:created_at;type:text"`
 }
 
 func (Agent) TableName() string {
@@ -12,13 +44,144 @@ func (Agent) TableName() string {
 }
 
 type Repostiory interface {
-	Create(ctx context.Context, agent *Agent) error
+	// Enroll creates the agent and spends one use of its registration token
+	// in one transaction, so a failed insert does not burn a use
+	Enroll(ctx context.Context, agent *Agent, now time.Time) error
 	GetById(ctx context.Context, ID string) (*Agent, error)
 	GetAll(ctx context.Context) ([]Agent, error)
+	// UpdateCredential moves the agent from generation `from` to `to`, failing if
+	// another rotation already happened
+	UpdateCredential(ctx context.Context, ID string, from, to int, expiresAt time.Time) error
+	RevokeCredential(ctx context.Context, ID string, now time.Time) error
+	// Touch records a poll, writing at most once per interval unless the agent was offline
+	Touch(ctx context.Context, ID string, now time.Time, interval time.Duration) error
+	// MarkOffline flags agents not seen since cutoff and returns the ones it flagged
+	MarkOffline(ctx context.Context, cutoff, now time.Time) ([]Agent, error)
+}
+
+// RegistrationToken is an admin-issued enrollment token. Only the SHA-256 of
+// the token is stored; the plaintext is returned once on creation.
+type RegistrationToken struct {
+	UUID        string            `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
+	TokenHash   string            `json:"-" gorm:"column:token_hash;type:text"`
+	Description string            `json:"description" gorm:"column:description;type:text"`
+	Environment string            `json:"environment" gorm:"column:environment;type:text"`
+	Labels      map[string]string `json:"labels" gorm:"column:labels;type:jsonb;serializer:json"`
+	Namespaces  []string          `json:"namespaces" gorm:"column:namespaces;type:jsonb;serializer:json"`
+	MaxUses     int               `json:"max_uses" gorm:"column:max_uses;type:int"`
+	UsedCount   int               `json:"used_count" gorm:"column:used_count;type:int"`
+	ExpiresAt   time.Time         `json:"expires_at" gorm:"column:expires_at"`
+	RevokedAt   *time.Time        `json:"revoked_at" gorm:"column:revoked_at"`
+	CreatedAt   string            `json:"created_at" gorm:"column:created_at;type:text"`
+}
+
+func (RegistrationToken) TableName() string {
+	return "registration_tokens"
+}
+
+// Usable reports whether the token can still enroll an agent at the given time
+func (t *RegistrationToken) Usable(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt) && t.UsedCount < t.MaxUses
+}
+
+type RegistrationTokenRepository interface {
+	Create(ctx context.Context, token *RegistrationToken) error
+	GetByHash(ctx context.Context, hash string) (*RegistrationToken, error)
+	GetAll(ctx context.Context) ([]RegistrationToken, error)
+	Revoke(ctx context.Context, ID string, now time.Time) error
 }
 
 type Usecase interface {
-	Create(ctx context.Context) (string, error)
-	CreateRegistrationToken(ctx context.Context) (string, error)
+	Create(ctx context.Context, input *InputRegister, token *RegistrationToken) (*Credential, error)
+	GetAll(ctx context.Context) ([]Agent, error)
+	GetById(ctx context.Context, ID string) (*Agent, error)
+
+	RotateCredential(ctx context.Context, agent *Agent) (*Credential, error)
+	RevokeCredential(ctx context.Context, ID string) error
+	ValidateCredential(ctx context.Context, credential string) (*Agent, *CredentialClaims, error)
+	RenewCertificate(ctx context.Context, agent *Agent, input *InputRenewCertificate) (*Certificate, error)
+
+	CreateRegistrationToken(ctx context.Context, input *InputRegistrationToken) (*CreatedRegistrationToken, error)
+	GetRegistrationTokens(ctx context.Context) ([]RegistrationToken, error)
+	RevokeRegistrationToken(ctx context.Context, ID string) error
+	ValidateRegistrationToken(ctx context.Context, token string) (*RegistrationToken, error)
+
+	// MonitorOffline flags agents that stopped polling until ctx is cancelled
+	MonitorOffline(ctx context.Context)
 }
 
+// InputRegister is the host metadata an agent reports when it enrolls
+type InputRegister struct {
+	Hostname     string            `json:"hostname" binding:"required"`
+	IPAddress    string            `json:"ip_address" binding:"omitempty,ip"`
+	OS           string            `json:"os" binding:"required"`
+	Arch         string            `json:"arch" binding:"required"`
+	AgentVersion string            `json:"agent_version" binding:"required"`
+	WorkerURLs   []string          `json:"worker_urls" binding:"omitempty,dive,url"`
+	Labels       map[string]string `json:"labels"`
+	// CSR is an optional PEM certificate request for an mTLS client certificate
+	CSR string `json:"csr"`
+}
+
+type InputRenewCertificate struct {
+	CSR string `json:"csr" binding:"required"`
+}
+
+type InputRegistrationToken struct {
+	Description string            `json:"description"`
+	Environment string            `json:"environment"`
+	Labels      map[string]string `json:"labels"`
+	// Namespaces the enrolled agents may read, defaults to ["default"]
+	Namespaces []string `json:"namespaces"`
+	// MaxUses defaults to 1 (one-time token)
+	MaxUses int `json:"max_uses" binding:"omitempty,min=1"`
+	// ExpiresIn is the lifetime in seconds, defaults to 24 hours
+	ExpiresIn int `json:"expires_in" binding:"omitempty,min=60"`
+}
+
+// CreatedRegistrationToken carries the plaintext token, which is never retrievable again
+type CreatedRegistrationToken struct {
+	Token string `json:"token"`
+	RegistrationToken
+}
+
+// Credential is what an agent presents to the controller on every call
+type Credential struct {
+	AgentID    string    `json:"agent_id"`
+	Credential string    `json:"credential"`
+	Generation int       `json:"generation"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Certificate is set when the agent enrolled with a CSR
+	Certificate *Certificate `json:"certificate,omitempty"`
+	// ConfigSigningKeys are the base64 Ed25519 public keys the controller signs
+	// configs with; agents without config_signing.trusted_keys pin them
+	ConfigSigningKeys []string `json:"config_signing_keys,omitempty"`
+}
+
+// Certificate is an mTLS client certificate issued by the controller CA
+type Certificate struct {
+	Certificate   string    `json:"certificate"`
+	CACertificate string    `json:"ca_certificate"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// CredentialClaims are the claims of the signed agent credential JWT. The
+// subject is the agent id.
+type CredentialClaims struct {
+	Environment string   `json:"env"`
+	Namespaces  []string `json:"namespaces"`
+	Generation  int      `json:"gen"`
+	// Legacy marks a pre-JWT HMAC credential accepted during the migration window
+	Legacy bool `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// AllowsNamespace reports whether the credential may read the given namespace
+func (c *CredentialClaims) AllowsNamespace(namespace string) bool {
+	for _, ns := range c.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
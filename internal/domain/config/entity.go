@@ -1,13 +1,72 @@
 package config
 
-import "context"
+import (
+	"context"
+	"distributed_system/internal/domain/agents"
+	"distributed_system/pkg/crypto"
+)
 
 type Config struct {
 	UUID      string `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
+	// Namespace the config belongs to; versions are counted per namespace
+	Namespace string `json:"namespace" gorm:"column:namespace;type:text"`
 	Version   int  `json:"version" gorm:"column:version;type:int"`
 	ConfigURL string `json:"config_url" gorm:"column:config_url;type:text"`
 	PoolingInterval int `json:"pooling_interval" gorm:"column:pooling_interval;type:int"`
 	CreatedAt string `json:"created_at" gorm:"column:created_at;type:text"`
+	Signature      string `json:"signature,omitempty" gorm:"column:signature;type:text"`
+	SignatureKeyID string `json:"signature_key_id,omitempty" gorm:"column:signature_key_id;type:text"`
+}
+
+// SignedPayload is the part of a config the controller signs and that agents
+// and workers verify before applying it
+type SignedPayload struct {
+	UUID string `json:"uuid"`
+	// Namespace is left out for the default namespace, so signatures made
+	// before configs had namespaces still verify
+	Namespace       string `json:"namespace,omitempty"`
+	Version         int    `json:"version"`
+	ConfigURL       string `json:"config_url"`
+	PoolingInterval int    `json:"pooling_interval"`
+}
+
+// Canonical is the exact byte encoding that gets signed
+func (p SignedPayload) Canonical() ([]byte, error) {
+	return crypto.CanonicalJSON(p)
+}
+
+func (c *Config) SignedPayload() SignedPayload {
+	namespace := c.Namespace
+	if namespace == agents.DefaultNamespace {
+		namespace = ""
+	}
+
+	return SignedPayload{
+		UUID:            c.UUID,
+		Namespace:       namespace,
+		Version:         c.Version,
+		ConfigURL:       c.ConfigURL,
+		PoolingInterval: c.PoolingInterval,
+	}
+}
+
+// InNamespace reports whether the config belongs to the namespace. Configs
+// from controllers without namespaces belong to the default one.
+func (c *Config) InNamespace(namespace string) bool {
+	if c.Namespace == "" {
+		return namespace == agents.DefaultNamespace
+	}
+	return c.Namespace == namespace
+}
+
+// VerifySignature checks the config against the trusted keys
+func (c *Config) VerifySignature(verifier *crypto.Ed25519Verifier) error {
+	payload, err := c.SignedPayload().Canonical()
+	if err != nil {
+		return err
+	}
+
+	return verifier.Verify(payload, c.SignatureKeyID, c.Signature)
 }
 
 func (Config) TableName() string {
@@ -15,15 +74,24 @@ func (Config) TableName() string {
 }
 
 type Repository interface {
-	GetLatestConfig(ctx context.Context) (*Config, error)
+	GetLatestConfig(ctx context.Context, namespace string) (*Config, error)
+	GetByVersion(ctx context.Context, namespace string, version int) (*Config, error)
+	ListUnsigned(ctx context.Context) ([]Config, error)
 	Create(ctx context.Context, config *Config) error
 	Update(ctx context.Context, config *Config) error
 }
 
+// Every namespace has its own config history; the usecase methods act on the
+// latest config of one namespace
 type Usecase interface {
-	GetLatestConfig(ctx context.Context, agentID *string) (*Config, error)
-	Create(ctx context.Context, save *SaveCreate) (*Config, error)
-	Update(ctx context.Context, save *SaveUpdate) error
+	GetLatestConfig(ctx context.Context, namespace string, agentID *string) (*Config, error)
+	Create(ctx context.Context, namespace string, save *SaveCreate) (*Config, error)
+	Update(ctx context.Context, namespace string, save *SaveUpdate) error
+	// Rollback publishes an older version's settings again as a new version
+	Rollback(ctx context.Context, namespace string, save *SaveRollback) (*Config, error)
+	// SignUnsignedConfigs signs the versions stored before configs were
+	// signed. It runs once at startup.
+	SignUnsignedConfigs(ctx context.Context) error
 }
 
 type SaveCreate struct {
@@ -34,4 +102,20 @@ type SaveCreate struct {
 type SaveUpdate struct {
 	ConfigUrl string `json:"config_url" binding:"omitempty"`
 	PoolingInterval *int `json:"pooling_interval" binding:"omitempty,min=30"`
+}
+
+// SaveRollback names the older version to publish again
+type SaveRollback struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+// RolledBack is the data of the config.rolled_back event
+type RolledBack struct {
+	Namespace string `json:"namespace"`
+	// FromVersion was the latest version before the rollback
+	FromVersion int `json:"from_version"`
+	// RestoredVersion is the version whose settings were published again
+	RestoredVersion int `json:"restored_version"`
+	// Config is the new version carrying them
+	Config *Config `json:"config"`
 }
\ No newline at end of file
@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	EventConfigPublished  = "config.published"
+	EventConfigRolledBack = "config.rolled_back"
+	EventAgentRegistered  = "agent.registered"
+	EventAgentOffline     = "agent.offline"
+	EventRolloutHalted    = "rollout.halted"
+	// EventPing is only sent by the test endpoint
+	EventPing = "ping"
+)
+
+// Events are the event types a webhook can subscribe to
+var Events = []string{
+	EventConfigPublished,
+	EventConfigRolledBack,
+	EventAgentRegistered,
+	EventAgentOffline,
+	EventRolloutHalted,
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// SecretPrefix starts every webhook signing secret
+const SecretPrefix = "whsec_"
+
+// Webhook is an endpoint that receives the events it subscribed to as signed
+// JSON POST requests. The secret is kept in plaintext because every delivery
+// is signed with it; it is returned once on creation.
+type Webhook struct {
+	UUID       string     `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
+	Name       string     `json:"name" gorm:"column:name;type:text"`
+	URL        string     `json:"url" gorm:"column:url;type:text"`
+	Events     []string   `json:"events" gorm:"column:events;type:jsonb;serializer:json"`
+	Secret     string     `json:"-" gorm:"column:secret;type:text"`
+	CreatedBy  string     `json:"created_by" gorm:"column:created_by;type:text"`
+	DisabledAt *time.Time `json:"disabled_at" gorm:"column:disabled_at"`
+	CreatedAt  string     `json:"created_at" gorm:"column:created_at;type:text"`
+}
+
+func (Webhook) TableName() string { return "webhooks" }
+
+// Subscribed reports whether the webhook wants the event type
+func (w *Webhook) Subscribed(eventType string) bool {
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON body of every delivery
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Delivery is one event sent to one webhook, with its retry state. The
+// deliveries of a webhook are its delivery log.
+type Delivery struct {
+	UUID           string     `json:"uuid" gorm:"column:uuid;type:text;primaryKey"`
+	WebhookID      string     `json:"webhook_id" gorm:"column:webhook_id;type:text"`
+	EventID        string     `json:"event_id" gorm:"column:event_id;type:text"`
+	EventType      string     `json:"event_type" gorm:"column:event_type;type:text"`
+	Payload        string     `json:"payload" gorm:"column:payload;type:text"`
+	Status         string     `json:"status" gorm:"column:status;type:text"`
+	Attempts       int        `json:"attempts" gorm:"column:attempts;type:int"`
+	ResponseStatus int        `json:"response_status" gorm:"column:response_status;type:int"`
+	LastError      string     `json:"last_error" gorm:"column:last_error;type:text"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at" gorm:"column:next_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at" gorm:"column:delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+func (Delivery) TableName() string { return "webhook_deliveries" }
+
+type Repository interface {
+	Create(ctx context.Context, webhook *Webhook) error
+	GetById(ctx context.Context, ID string) (*Webhook, error)
+	GetAll(ctx context.Context) ([]Webhook, error)
+	// GetSubscribed returns the enabled webhooks subscribed to the event type
+	GetSubscribed(ctx context.Context, eventType string) ([]Webhook, error)
+	Update(ctx context.Context, webhook *Webhook) error
+	Delete(ctx context.Context, ID string) error
+}
+
+type DeliveryRepository interface {
+	Create(ctx context.Context, delivery *Delivery) error
+	Update(ctx context.Context, delivery *Delivery) error
+	// ClaimDue leases up to limit pending deliveries whose next attempt is due,
+	// pushing their next attempt to now+lease so no other controller sends them
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	GetByWebhook(ctx context.Context, webhookID string, limit int) ([]Delivery, error)
+	GetById(ctx context.Context, ID string) (*Delivery, error)
+}
+
+// Publisher is how other usecases emit events. Publishing never fails the
+// caller; delivery problems end up in the delivery log.
+type Publisher interface {
+	Publish(ctx context.Context, eventType string, data interface{})
+}
+
+type InputCreateWebhook struct {
+	Name   string   `json:"name" binding:"required"`
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=config.published config.rolled_back agent.registered agent.offline rollout.halted"`
+}
+
+type InputUpdateWebhook struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url" binding:"omitempty,url"`
+	Events  []string `json:"events" binding:"omitempty,min=1,dive,oneof=config.published config.rolled_back agent.registered agent.offline rollout.halted"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// CreatedWebhook carries the signing secret, which is never retrievable again
+type CreatedWebhook struct {
+	Secret string `json:"secret"`
+	Webhook
+}
+
+type Usecase interface {
+	Publisher
+
+	Create(ctx context.Context, actorID string, input *InputCreateWebhook) (*CreatedWebhook, error)
+	GetAll(ctx context.Context) ([]Webhook, error)
+	GetById(ctx context.Context, ID string) (*Webhook, error)
+	Update(ctx context.Context, ID string, input *InputUpdateWebhook) (*Webhook, error)
+	Delete(ctx context.Context, ID string) error
+	GetDeliveries(ctx context.Context, webhookID string) ([]Delivery, error)
+	// Redeliver queues a delivery again, e.g. after the receiver was fixed
+	Redeliver(ctx context.Context, webhookID, deliveryID string) (*Delivery, error)
+	// Ping queues a ping event to check the endpoint and its signature handling
+	Ping(ctx context.Context, ID string) (*Delivery, error)
+
+	// Run delivers due events until ctx is cancelled
+	Run(ctx context.Context)
+}
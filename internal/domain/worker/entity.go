@@ -1,12 +1,17 @@
 package worker
 
-import "context"
+import (
+	"context"
+	"distributed_system/internal/domain/config"
+)
 
 type WorkerConfig struct {
 	ConfigURL       string `json:"config_url"`
 	PoolingInterval int    `json:"pooling_interval"`
 	Version         int    `json:"version"`
 	UUID            string `json:"uuid"`
+	Signature       string `json:"signature,omitempty"`
+	SignatureKeyID  string `json:"signature_key_id,omitempty"`
 }
 
 type UpdateConfigRequest struct {
@@ -14,9 +19,44 @@ type UpdateConfigRequest struct {
 	PoolingInterval int    `json:"pooling_interval" binding:"required,min=30"`
 	Version         int    `json:"version" binding:"required"`
 	UUID            string `json:"uuid" binding:"required"`
+	// Signature is the controller's signature over the fields above
+	Signature      string `json:"signature"`
+	SignatureKeyID string `json:"signature_key_id"`
+	// Namespace the config belongs to, "default" when empty
+	Namespace string `json:"namespace"`
+	// ConfigSigningKeys are the controller keys the agent verifies with; a
+	// worker without config_signing.trusted_keys pins them on the first push
+	ConfigSigningKeys []string `json:"config_signing_keys,omitempty"`
 }
 
+// Config rebuilds the controller config the signature was made over
+func (r UpdateConfigRequest) Config() *config.Config {
+	return &config.Config{
+		UUID:            r.UUID,
+		Namespace:       r.Namespace,
+		Version:         r.Version,
+		ConfigURL:       r.ConfigURL,
+		PoolingInterval: r.PoolingInterval,
+		Signature:       r.Signature,
+		SignatureKeyID:  r.SignatureKeyID,
+	}
+}
+
+// Announcement is how a worker makes itself known to its local agent, as the
+// body of the agent's POST /workers or as a file in its discovery directory
+type Announcement struct {
+	// URL is the base of the worker's private API, e.g. http://host:8082/private
+	URL string `json:"url" binding:"required,url"`
+	// HealthURL is the worker's public /health endpoint
+	HealthURL   string   `json:"health_url" binding:"required,url"`
+	InternalKey string   `json:"internal_key,omitempty"`
+	Namespaces  []string `json:"namespaces"`
+}
+
+// The worker keeps one config per namespace; Hit runs the task of one of them
 type Usecase interface {
-	Hit(ctx context.Context) (any, error)
+	Hit(ctx context.Context, namespace string) (any, error)
 	UpdateConfig(ctx context.Context, req UpdateConfigRequest) error
+	// AppliedVersion lets the agent check which version the worker runs
+	AppliedVersion(ctx context.Context, namespace string) int
 }
\ No newline at end of file
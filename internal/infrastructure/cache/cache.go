@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"distributed_system/internal/domain/agents"
 	"distributed_system/internal/domain/config"
 	"distributed_system/internal/infrastructure/redis"
 	"encoding/json"
@@ -24,18 +25,26 @@ func NewConfigCache(redisClient *redis.Client) *ConfigCache {
 	}
 }
 
+// latestConfigKey keeps LatestConfigKey for the default namespace
+func latestConfigKey(namespace string) string {
+	if namespace == agents.DefaultNamespace {
+		return LatestConfigKey
+	}
+	return LatestConfigKey + ":" + namespace
+}
+
 func (c *ConfigCache) SetConfig(ctx context.Context, config *config.Config) error {
 	data, err :=  json.Marshal(config)
 	if err != nil {
 		return err
 	}
-	return c.redis.Set(ctx, LatestConfigKey, data, DefaultCacheTTL)
+	return c.redis.Set(ctx, latestConfigKey(config.Namespace), data, DefaultCacheTTL)
 }
 
-func (c *ConfigCache) GetConfig(ctx context.Context) (*config.Config, error) {
+func (c *ConfigCache) GetConfig(ctx context.Context, namespace string) (*config.Config, error) {
 	var cfg config.Config
 
-	value, err := c.redis.Get(ctx, LatestConfigKey)
+	value, err := c.redis.Get(ctx, latestConfigKey(namespace))
 	if err != nil {
 		return nil, err
 	}
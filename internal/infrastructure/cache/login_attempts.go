@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"distributed_system/internal/infrastructure/redis"
+	"time"
+)
+
+const (
+	loginFailuresPrefix = "auth:login:failures:"
+	loginLockPrefix     = "auth:login:lock:"
+	loginLockoutsPrefix = "auth:login:lockouts:"
+
+	// lockoutMemory is how long earlier lockouts keep doubling the next one
+	lockoutMemory = 24 * time.Hour
+)
+
+// LoginAttemptCache counts failed logins per scope (ip or account) and keeps
+// the resulting lockouts
+type LoginAttemptCache struct {
+	redis *redis.Client
+}
+
+func NewLoginAttemptCache(redisClient *redis.Client) *LoginAttemptCache {
+	return &LoginAttemptCache{
+		redis: redisClient,
+	}
+}
+
+func attemptKey(prefix, scope, subject string) string {
+	return prefix + scope + ":" + subject
+}
+
+// LockedFor returns how long the subject is still locked out, zero when it is not
+func (c *LoginAttemptCache) LockedFor(ctx context.Context, scope, subject string) (time.Duration, error) {
+	ttl, err := c.redis.TTL(ctx, attemptKey(loginLockPrefix, scope, subject)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// -2 (missing) and -1 (no expiry) both come back negative
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+// RecordFailure counts a failed attempt inside the window and returns the
+// number of failures so far
+func (c *LoginAttemptCache) RecordFailure(ctx context.Context, scope, subject string, window time.Duration) (int64, error) {
+	key := attemptKey(loginFailuresPrefix, scope, subject)
+
+	count, err := c.redis.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 1 {
+		if err := c.redis.Expire(ctx, key, window); err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+// Lock locks the subject out for base doubled for every lockout in the last
+// day, capped at max, and clears its failure count
+func (c *LoginAttemptCache) Lock(ctx context.Context, scope, subject string, base, max time.Duration) (time.Duration, error) {
+	lockoutsKey := attemptKey(loginLockoutsPrefix, scope, subject)
+
+	lockouts, err := c.redis.Incr(ctx, lockoutsKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if err := c.redis.Expire(ctx, lockoutsKey, lockoutMemory); err != nil {
+		return 0, err
+	}
+
+	duration := base
+	for i := int64(1); i < lockouts && duration < max; i++ {
+		duration *= 2
+	}
+	if duration > max {
+		duration = max
+	}
+
+	if err := c.redis.Set(ctx, attemptKey(loginLockPrefix, scope, subject), lockouts, duration); err != nil {
+		return 0, err
+	}
+
+	return duration, c.redis.Del(ctx, attemptKey(loginFailuresPrefix, scope, subject))
+}
+
+// Reset forgets failures and earlier lockouts after a successful login
+func (c *LoginAttemptCache) Reset(ctx context.Context, scope, subject string) error {
+	return c.redis.Del(ctx,
+		attemptKey(loginFailuresPrefix, scope, subject),
+		attemptKey(loginLockoutsPrefix, scope, subject),
+	)
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"distributed_system/internal/infrastructure/redis"
+	"encoding/json"
+	"errors"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	refreshTokenKeyPrefix = "auth:refresh:"
+	adminSessionsPrefix   = "auth:sessions:"
+	revokedTokenKeyPrefix = "auth:revoked:"
+	mfaChallengeKeyPrefix = "auth:mfa:"
+	oidcStateKeyPrefix    = "auth:oidc:"
+)
+
+// ErrSessionNotFound is returned for unknown, expired or already used refresh tokens
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionCache keeps admin refresh tokens (by hash), pending 2FA login
+// challenges, pending single sign-on logins and the jti blacklist of logged
+// out access tokens
+type SessionCache struct {
+	redis *redis.Client
+}
+
+func NewSessionCache(redisClient *redis.Client) *SessionCache {
+	return &SessionCache{
+		redis: redisClient,
+	}
+}
+
+// StoreRefreshToken saves a refresh token hash for the admin and indexes it so
+// every session of that admin can be ended at once
+func (c *SessionCache) StoreRefreshToken(ctx context.Context, tokenHash, adminID string, ttl time.Duration) error {
+	sessionsKey := adminSessionsPrefix + adminID
+
+	pipe := c.redis.TxPipeline()
+	pipe.Set(ctx, refreshTokenKeyPrefix+tokenHash, adminID, ttl)
+	pipe.SAdd(ctx, sessionsKey, tokenHash)
+	pipe.Expire(ctx, sessionsKey, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ConsumeRefreshToken deletes the refresh token and returns its admin id, so
+// each refresh token can be used exactly once
+func (c *SessionCache) ConsumeRefreshToken(ctx context.Context, tokenHash string) (string, error) {
+	adminID, err := c.redis.GetDel(ctx, refreshTokenKeyPrefix+tokenHash).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", ErrSessionNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	c.redis.SRem(ctx, adminSessionsPrefix+adminID, tokenHash)
+	return adminID, nil
+}
+
+// DeleteRefreshToken ends a single session; unknown tokens are ignored
+func (c *SessionCache) DeleteRefreshToken(ctx context.Context, tokenHash string) error {
+	_, err := c.ConsumeRefreshToken(ctx, tokenHash)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	}
+	return err
+}
+
+// DeleteAdminSessions ends every refresh token issued to the admin
+func (c *SessionCache) DeleteAdminSessions(ctx context.Context, adminID string) error {
+	sessionsKey := adminSessionsPrefix + adminID
+
+	hashes, err := c.redis.SMembers(ctx, sessionsKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(hashes)+1)
+	for _, hash := range hashes {
+		keys = append(keys, refreshTokenKeyPrefix+hash)
+	}
+	keys = append(keys, sessionsKey)
+
+	return c.redis.Del(ctx, keys...)
+}
+
+// RevokeAccessToken blacklists a jti until the token would have expired anyway
+func (c *SessionCache) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return c.redis.Set(ctx, revokedTokenKeyPrefix+jti, 1, ttl)
+}
+
+func (c *SessionCache) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := c.redis.Exists(ctx, revokedTokenKeyPrefix+jti)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// StoreMFAChallenge remembers which admin passed the password step of a 2FA login
+func (c *SessionCache) StoreMFAChallenge(ctx context.Context, tokenHash, adminID string, ttl time.Duration) error {
+	return c.redis.Set(ctx, mfaChallengeKeyPrefix+tokenHash, adminID, ttl)
+}
+
+// ConsumeMFAChallenge deletes the challenge and returns its admin id, so each
+// challenge gets exactly one code attempt even under concurrent requests
+func (c *SessionCache) ConsumeMFAChallenge(ctx context.Context, tokenHash string) (string, error) {
+	adminID, err := c.redis.GetDel(ctx, mfaChallengeKeyPrefix+tokenHash).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", ErrSessionNotFound
+	}
+	return adminID, err
+}
+
+// OIDCState is what a pending single sign-on login needs back on the callback
+type OIDCState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func (c *SessionCache) StoreOIDCState(ctx context.Context, stateHash string, state *OIDCState, ttl time.Duration) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return c.redis.Set(ctx, oidcStateKeyPrefix+stateHash, data, ttl)
+}
+
+// ConsumeOIDCState returns and deletes a pending login so a callback cannot be replayed
+func (c *SessionCache) ConsumeOIDCState(ctx context.Context, stateHash string) (*OIDCState, error) {
+	data, err := c.redis.GetDel(ctx, oidcStateKeyPrefix+stateHash).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state OIDCState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
@@ -21,7 +21,7 @@ func NewAdminRepository(db *gorm.DB) admin.Repostory {
 func (r *repository) GetByEmail(ctx context.Context, email string) (*admin.Admin, error) {
 	var admin admin.Admin
 
-	if err := r.db.First(&admin, "email = ?", email).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&admin, "email = ?", email).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.NotFound("admin")
 		}
@@ -29,4 +29,79 @@ func (r *repository) GetByEmail(ctx context.Context, email string) (*admin.Admin
 	}
 
 	return &admin, nil
-}
\ No newline at end of file
+}
+
+func (r *repository) GetById(ctx context.Context, ID string) (*admin.Admin, error) {
+	var admin admin.Admin
+
+	if err := r.db.WithContext(ctx).First(&admin, "uuid = ?", ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NotFound("admin")
+		}
+		return nil, errors.Database(err)
+	}
+
+	return &admin, nil
+}
+
+func (r *repository) GetByOIDCSubject(ctx context.Context, issuer, subject string) (*admin.Admin, error) {
+	var admin admin.Admin
+
+	if err := r.db.WithContext(ctx).First(&admin, "oidc_issuer = ? AND oidc_subject = ?", issuer, subject).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NotFound("admin")
+		}
+		return nil, errors.Database(err)
+	}
+
+	return &admin, nil
+}
+
+func (r *repository) GetAll(ctx context.Context) ([]admin.Admin, error) {
+	var admins []admin.Admin
+
+	if err := r.db.WithContext(ctx).Order("created_at ASC").Find(&admins).Error; err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return admins, nil
+}
+
+func (r *repository) Create(ctx context.Context, account *admin.Admin) error {
+	if err := r.db.WithContext(ctx).Create(account).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *repository) Update(ctx context.Context, account *admin.Admin) error {
+	if err := r.db.WithContext(ctx).Save(account).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, ID string) error {
+	res := r.db.WithContext(ctx).Delete(&admin.Admin{}, "uuid = ?", ID)
+	if res.Error != nil {
+		return errors.Database(res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return errors.NotFound("admin")
+	}
+
+	return nil
+}
+
+func (r *repository) CountActiveByRole(ctx context.Context, role admin.Role) (int64, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&admin.Admin{}).Where("disabled_at IS NULL AND role = ?", role).Count(&count).Error; err != nil {
+		return 0, errors.Database(err)
+	}
+
+	return count, nil
+}
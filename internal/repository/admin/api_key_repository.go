@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"context"
+	"distributed_system/internal/domain/admin"
+	"distributed_system/pkg/errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) admin.APIKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *admin.APIKey) error {
+	if err := r.db.WithContext(ctx).Create(key).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*admin.APIKey, error) {
+	var key admin.APIKey
+	if err := r.db.WithContext(ctx).First(&key, "key_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NotFound("api key")
+		}
+		return nil, errors.Database(err)
+	}
+
+	return &key, nil
+}
+
+func (r *apiKeyRepository) GetAll(ctx context.Context) ([]admin.APIKey, error) {
+	var keys []admin.APIKey
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, ID string, now time.Time) error {
+	res := r.db.WithContext(ctx).
+		Model(&admin.APIKey{}).
+		Where("uuid = ? AND revoked_at IS NULL", ID).
+		Update("revoked_at", now)
+
+	if res.Error != nil {
+		return errors.Database(res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return errors.NotFound("api key")
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) Touch(ctx context.Context, ID string, now time.Time, interval time.Duration) error {
+	err := r.db.WithContext(ctx).
+		Model(&admin.APIKey{}).
+		Where("uuid = ? AND (last_used_at IS NULL OR last_used_at < ?)", ID, now.Add(-interval)).
+		Update("last_used_at", now).Error
+
+	if err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package admin
+
+import (
+	"context"
+	"distributed_system/internal/domain/admin"
+	"distributed_system/pkg/errors"
+
+	"gorm.io/gorm"
+)
+
+type loginLockoutRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginLockoutRepository(db *gorm.DB) admin.LoginLockoutRepository {
+	return &loginLockoutRepository{
+		db: db,
+	}
+}
+
+func (r *loginLockoutRepository) Create(ctx context.Context, lockout *admin.LoginLockout) error {
+	if err := r.db.WithContext(ctx).Create(lockout).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *loginLockoutRepository) GetRecent(ctx context.Context, limit int) ([]admin.LoginLockout, error) {
+	var lockouts []admin.LoginLockout
+
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Limit(limit).Find(&lockouts).Error; err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return lockouts, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"distributed_system/internal/domain/agents"
 	"distributed_system/pkg/errors"
+	"net/http"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -18,8 +20,26 @@ func NewAgentRepository(db *gorm.DB) agents.Repostiory {
 	}
 }
 
-func (r *repository) Create(ctx context.Context, agent *agents.Agent) error {
-	return r.db.WithContext(ctx).Create(agent).Error
+func (r *repository) Enroll(ctx context.Context, agent *agents.Agent, now time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&agents.RegistrationToken{}).
+			Where("uuid = ? AND revoked_at IS NULL AND expires_at > ? AND used_count < max_uses", agent.RegistrationTokenID, now).
+			Update("used_count", gorm.Expr("used_count + 1"))
+
+		if res.Error != nil {
+			return errors.Database(res.Error)
+		}
+
+		if res.RowsAffected == 0 {
+			return errors.New(errors.ErrCodeAlreadyUsed, "registration token is no longer valid").WithStatus(http.StatusUnauthorized)
+		}
+
+		if err := tx.Create(agent).Error; err != nil {
+			return errors.Database(err)
+		}
+
+		return nil
+	})
 }
 
 func (r *repository) GetById(ctx context.Context, ID string) (*agents.Agent, error) {
@@ -36,9 +56,82 @@ func (r *repository) GetById(ctx context.Context, ID string) (*agents.Agent, err
 
 func (r *repository) GetAll(ctx context.Context) ([]agents.Agent, error) {
 	var agents []agents.Agent
-	if err := r.db.Find(&agents).Error; err != nil {
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&agents).Error; err != nil {
 		return nil, errors.Database(err)
 	}
 
 	return agents, nil
-}
\ No newline at end of file
+}
+
+func (r *repository) UpdateCredential(ctx context.Context, ID string, from, to int, expiresAt time.Time) error {
+	res := r.db.WithContext(ctx).
+		Model(&agents.Agent{}).
+		Where("uuid = ? AND credential_generation = ? AND credential_revoked_at IS NULL", ID, from).
+		Updates(map[string]interface{}{
+			"credential_generation": to,
+			"credential_expires_at": expiresAt,
+		})
+
+	if res.Error != nil {
+		return errors.Database(res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return errors.New(errors.ErrCodeInvalidCredential, "agent credential was rotated or revoked").WithStatus(http.StatusConflict)
+	}
+
+	return nil
+}
+
+func (r *repository) RevokeCredential(ctx context.Context, ID string, now time.Time) error {
+	res := r.db.WithContext(ctx).
+		Model(&agents.Agent{}).
+		Where("uuid = ?", ID).
+		Update("credential_revoked_at", now)
+
+	if res.Error != nil {
+		return errors.Database(res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return errors.NotFound("agent")
+	}
+
+	return nil
+}
+
+func (r *repository) Touch(ctx context.Context, ID string, now time.Time, interval time.Duration) error {
+	err := r.db.WithContext(ctx).
+		Model(&agents.Agent{}).
+		Where("uuid = ? AND (last_seen_at IS NULL OR last_seen_at < ? OR offline_at IS NOT NULL)", ID, now.Add(-interval)).
+		Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"offline_at":   nil,
+		}).Error
+
+	if err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *repository) MarkOffline(ctx context.Context, cutoff, now time.Time) ([]agents.Agent, error) {
+	var list []agents.Agent
+
+	// flagging and returning in one statement keeps several controllers from
+	// reporting the same agent
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE agents SET offline_at = ?
+		WHERE offline_at IS NULL
+			AND credential_revoked_at IS NULL
+			AND last_seen_at < ?
+		RETURNING *`,
+		now, cutoff,
+	).Scan(&list).Error
+	if err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,66 @@
+package agents
+
+import (
+	"context"
+	"distributed_system/internal/domain/agents"
+	"distributed_system/pkg/errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type registrationTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRegistrationTokenRepository(db *gorm.DB) agents.RegistrationTokenRepository {
+	return &registrationTokenRepository{
+		db: db,
+	}
+}
+
+func (r *registrationTokenRepository) Create(ctx context.Context, token *agents.RegistrationToken) error {
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *registrationTokenRepository) GetByHash(ctx context.Context, hash string) (*agents.RegistrationToken, error) {
+	var token agents.RegistrationToken
+	if err := r.db.WithContext(ctx).First(&token, "token_hash = ?", hash).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NotFound("registration token")
+		}
+		return nil, errors.Database(err)
+	}
+
+	return &token, nil
+}
+
+func (r *registrationTokenRepository) GetAll(ctx context.Context) ([]agents.RegistrationToken, error) {
+	var tokens []agents.RegistrationToken
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return tokens, nil
+}
+
+func (r *registrationTokenRepository) Revoke(ctx context.Context, ID string, now time.Time) error {
+	res := r.db.WithContext(ctx).
+		Model(&agents.RegistrationToken{}).
+		Where("uuid = ? AND revoked_at IS NULL", ID).
+		Update("revoked_at", now)
+
+	if res.Error != nil {
+		return errors.Database(res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return errors.NotFound("registration token")
+	}
+
+	return nil
+}
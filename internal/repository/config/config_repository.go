@@ -21,9 +21,10 @@ func NewCOnfigRepository(db *gorm.DB, cache *cache.ConfigCache) config.Repositor
 	}
 }
 
-func (r *repository) GetLatestConfig(ctx context.Context) (*config.Config, error) {
+func (r *repository) GetLatestConfig(ctx context.Context, namespace string) (*config.Config, error) {
     var cfg config.Config
     res := r.db.WithContext(ctx).
+        Where("namespace = ?", namespace).
         Order("version DESC").
         First(&cfg) // First otomatis menambahkan LIMIT 1
     
@@ -37,6 +38,31 @@ func (r *repository) GetLatestConfig(ctx context.Context) (*config.Config, error
     return &cfg, nil
 }
 
+func (r *repository) GetByVersion(ctx context.Context, namespace string, version int) (*config.Config, error) {
+	var cfg config.Config
+	res := r.db.WithContext(ctx).
+		Where("namespace = ? AND version = ?", namespace, version).
+		First(&cfg)
+
+	if res.Error != nil {
+		if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.NotFound("config")
+		}
+		return nil, errors.Database(res.Error)
+	}
+
+	return &cfg, nil
+}
+
+// ListUnsigned returns every version stored before configs were signed
+func (r *repository) ListUnsigned(ctx context.Context) ([]config.Config, error) {
+	var cfgs []config.Config
+	if err := r.db.WithContext(ctx).Where("signature = ''").Order("version ASC").Find(&cfgs).Error; err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return cfgs, nil
+}
 
 func (r *repository) Create(ctx context.Context, config *config.Config) error {
 	err := r.db.WithContext(ctx).Create(config).Error
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"context"
+	"distributed_system/internal/domain/webhook"
+	"distributed_system/pkg/errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type deliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewDeliveryRepository(db *gorm.DB) webhook.DeliveryRepository {
+	return &deliveryRepository{
+		db: db,
+	}
+}
+
+func (r *deliveryRepository) Create(ctx context.Context, delivery *webhook.Delivery) error {
+	if err := r.db.WithContext(ctx).Create(delivery).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *deliveryRepository) Update(ctx context.Context, delivery *webhook.Delivery) error {
+	// Select("*") updates every column without Save's insert fallback, so a
+	// delivery removed with its webhook is not recreated
+	if err := r.db.WithContext(ctx).Model(delivery).Select("*").Updates(delivery).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *deliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]webhook.Delivery, error) {
+	var deliveries []webhook.Delivery
+
+	// SKIP LOCKED lets several controllers share the queue without sending twice
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE uuid IN (
+			SELECT uuid FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now.Add(lease), webhook.DeliveryPending, now, limit,
+	).Scan(&deliveries).Error
+	if err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *deliveryRepository) GetByWebhook(ctx context.Context, webhookID string, limit int) ([]webhook.Delivery, error) {
+	var deliveries []webhook.Delivery
+
+	err := r.db.WithContext(ctx).
+		Where("webhook_id = ?", webhookID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return deliveries, nil
+}
+
+func (r *deliveryRepository) GetById(ctx context.Context, ID string) (*webhook.Delivery, error) {
+	var delivery webhook.Delivery
+	if err := r.db.WithContext(ctx).First(&delivery, "uuid = ?", ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NotFound("webhook delivery")
+		}
+		return nil, errors.Database(err)
+	}
+
+	return &delivery, nil
+}
@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"context"
+	"distributed_system/internal/domain/webhook"
+	"distributed_system/pkg/errors"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) webhook.Repository {
+	return &repository{
+		db: db,
+	}
+}
+
+func (r *repository) Create(ctx context.Context, hook *webhook.Webhook) error {
+	if err := r.db.WithContext(ctx).Create(hook).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetById(ctx context.Context, ID string) (*webhook.Webhook, error) {
+	var hook webhook.Webhook
+	if err := r.db.WithContext(ctx).First(&hook, "uuid = ?", ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.NotFound("webhook")
+		}
+		return nil, errors.Database(err)
+	}
+
+	return &hook, nil
+}
+
+func (r *repository) GetAll(ctx context.Context) ([]webhook.Webhook, error) {
+	var hooks []webhook.Webhook
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&hooks).Error; err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return hooks, nil
+}
+
+func (r *repository) GetSubscribed(ctx context.Context, eventType string) ([]webhook.Webhook, error) {
+	var hooks []webhook.Webhook
+
+	filter, err := json.Marshal([]string{eventType})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.WithContext(ctx).
+		Where("disabled_at IS NULL AND events @> ?::jsonb", string(filter)).
+		Find(&hooks).Error
+	if err != nil {
+		return nil, errors.Database(err)
+	}
+
+	return hooks, nil
+}
+
+func (r *repository) Update(ctx context.Context, hook *webhook.Webhook) error {
+	if err := r.db.WithContext(ctx).Save(hook).Error; err != nil {
+		return errors.Database(err)
+	}
+
+	return nil
+}
+
+func (r *repository) Delete(ctx context.Context, ID string) error {
+	res := r.db.WithContext(ctx).Delete(&webhook.Webhook{}, "uuid = ?", ID)
+	if res.Error != nil {
+		return errors.Database(res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return errors.NotFound("webhook")
+	}
+
+	return nil
+}
@@ -4,39 +4,426 @@ import (
 	"context"
 	"distributed_system/internal/config"
 	"distributed_system/internal/domain/admin"
+	"distributed_system/internal/infrastructure/cache"
+	"distributed_system/pkg/crypto"
 	"distributed_system/pkg/errors"
+	"distributed_system/pkg/oidc"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	refreshTokenBytes      = 32
+)
+
 type AdminUsecase struct {
-	repository admin.Repostory
-	cfg        *config.Config
+	repository        admin.Repostory
+	lockoutRepository admin.LoginLockoutRepository
+	apiKeyRepository  admin.APIKeyRepository
+	cfg               *config.Config
+	sessions          *cache.SessionCache
+	attempts          *cache.LoginAttemptCache
+	oidc              *oidc.Provider
+}
+
+func NewAdminUsecase(repository admin.Repostory, lockoutRepository admin.LoginLockoutRepository, apiKeyRepository admin.APIKeyRepository, cfg *config.Config, sessions *cache.SessionCache, attempts *cache.LoginAttemptCache) admin.Usecase {
+	return &AdminUsecase{
+		repository:        repository,
+		lockoutRepository: lockoutRepository,
+		apiKeyRepository:  apiKeyRepository,
+		cfg:               cfg,
+		sessions:          sessions,
+		attempts:          attempts,
+		oidc:              newOIDCProvider(&cfg.Security.OIDC),
+	}
 }
 
-func NewAdminUsecase(repository admin.Repostory, cfg *config.Config) admin.Usecase {
-	return &AdminUsecase{repository: repository, cfg: cfg}
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
-func (u *AdminUsecase) Login(ctx context.Context, input *admin.InputLogin) (string, error) {
-	account, err := u.repository.GetByEmail(ctx, input.Email)
+// Login answers unknown emails, wrong passwords and disabled accounts with
+// the same invalid credentials error so accounts cannot be enumerated
+func (u *AdminUsecase) Login(ctx context.Context, input *admin.InputLogin) (*admin.LoginResult, error) {
+	email := normalizeEmail(input.Email)
+
+	if err := u.checkLockout(ctx, email, input.IP); err != nil {
+		return nil, err
+	}
+
+	account, err := u.repository.GetByEmail(ctx, email)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, errors.Wrap(err, "admin", "failed to get admin")
+	}
+
+	// compare against a dummy hash for unknown emails so both paths take as long
+	passwordHash := dummyPasswordHash()
+	if account != nil {
+		passwordHash = []byte(account.Password)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(passwordHash, []byte(input.Password)); err != nil || account == nil {
+		if err := u.recordFailure(ctx, email, input.IP); err != nil {
+			return nil, err
+		}
+		return nil, errors.ErrInvalidCredentials.Clone()
+	}
+
+	if account.Disabled() {
+		return nil, errors.ErrInvalidCredentials.Clone()
+	}
+
+	if account.TOTPEnabled() {
+		return u.startMFAChallenge(ctx, account)
+	}
+
+	if err := u.attempts.Reset(ctx, admin.LockoutScopeAccount, email); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to reset login attempts")
+	}
+
+	return u.issueSession(ctx, account)
+}
+
+// issueSession signs a short-lived access token and stores a fresh refresh token
+func (u *AdminUsecase) issueSession(ctx context.Context, account *admin.Admin) (*admin.LoginResult, error) {
+	accessTTL := u.cfg.Security.AccessTokenTTL
+	if accessTTL <= 0 {
+		accessTTL = defaultAccessTokenTTL
+	}
+	refreshTTL := u.cfg.Security.RefreshTokenTTL
+	if refreshTTL <= 0 {
+		refreshTTL = defaultRefreshTokenTTL
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(accessTTL)
+
+	token, err := u.cfg.Security.JWTKeyring.SignJWT(&admin.Claims{
+		Role: account.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   account.UUID,
+			Audience:  jwt.ClaimStrings{admin.TokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        uuid.New().String(),
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "admin", "failed to create token")
+	}
+
+	refreshToken, err := crypto.RandomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to generate refresh token")
+	}
+
+	if err := u.sessions.StoreRefreshToken(ctx, crypto.HashToken(refreshToken), account.UUID, refreshTTL); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to store session")
+	}
+
+	return &admin.LoginResult{
+		Token:              token,
+		ExpiresAt:          &expiresAt,
+		RefreshToken:       refreshToken,
+		MustChangePassword: account.MustChangePassword,
+	}, nil
+}
+
+func (u *AdminUsecase) Refresh(ctx context.Context, input *admin.InputRefresh) (*admin.LoginResult, error) {
+	adminID, err := u.sessions.ConsumeRefreshToken(ctx, crypto.HashToken(input.RefreshToken))
+	if err != nil {
+		if errors.Is(err, cache.ErrSessionNotFound) {
+			return nil, errors.ErrInvalidToken.Clone()
+		}
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to read session")
+	}
+
+	account, err := u.activeAccount(ctx, adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.issueSession(ctx, account)
+}
+
+func (u *AdminUsecase) Logout(ctx context.Context, claims *admin.Claims, input *admin.InputLogout) error {
+	if err := u.sessions.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to revoke token")
+	}
+
+	if input.RefreshToken != "" {
+		if err := u.sessions.DeleteRefreshToken(ctx, crypto.HashToken(input.RefreshToken)); err != nil {
+			return errors.Wrap(err, errors.ErrCodeInternal, "failed to end session")
+		}
+	}
+
+	return nil
+}
+
+func (u *AdminUsecase) Authenticate(ctx context.Context, claims *admin.Claims) (*admin.Admin, error) {
+	if claims.ID == "" {
+		return nil, errors.ErrInvalidToken.Clone()
+	}
+
+	revoked, err := u.sessions.IsAccessTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to check token")
+	}
+	if revoked {
+		return nil, errors.ErrInvalidToken.Clone()
+	}
+
+	return u.activeAccount(ctx, claims.Subject)
+}
+
+func (u *AdminUsecase) activeAccount(ctx context.Context, ID string) (*admin.Admin, error) {
+	if ID == "" {
+		return nil, errors.ErrInvalidToken.Clone()
+	}
+
+	account, err := u.repository.GetById(ctx, ID)
 	if err != nil {
 		if errors.IsNotFound(err) {
-			return "", errors.NotFound("admin")
+			return nil, errors.ErrUnauthorized.Clone()
 		}
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get admin")
+	}
 
-		return "", errors.Wrap(err, "admin", "failed to get admin")
+	if account.Disabled() {
+		return nil, errors.New(errors.ErrCodeUnauthorized, "admin account is disabled").WithStatus(http.StatusUnauthorized)
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(input.Password)); err != nil {
-		return "", errors.Wrap(err, "admin", "invalid password")
+	return account, nil
+}
+
+// Create adds an admin with an initial password that must be changed on first login
+func (u *AdminUsecase) Create(ctx context.Context, input *admin.InputCreateAdmin) (*admin.Admin, error) {
+	email := normalizeEmail(input.Email)
+
+	_, err := u.repository.GetByEmail(ctx, email)
+	if err == nil {
+		return nil, errors.Duplicate("admin")
+	}
+	if !errors.IsNotFound(err) {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get admin")
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &admin.Claims{Role: "admin"}).SignedString([]byte(u.cfg.Security.JWTSecret))
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return "", errors.Wrap(err, "admin", "failed to create token")
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to hash password")
+	}
+
+	role := input.Role
+	if role == "" {
+		role = admin.RoleViewer
 	}
 
-	return token, nil
-}
\ No newline at end of file
+	account := &admin.Admin{
+		UUID:               uuid.New().String(),
+		Email:              email,
+		Password:           string(hashedPassword),
+		Role:               role,
+		MustChangePassword: true,
+		TOTPRecoveryCodes:  []string{},
+		CreatedAt:          time.Now().Format(time.RFC3339),
+	}
+
+	if err := u.repository.Create(ctx, account); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to create admin")
+	}
+
+	return account, nil
+}
+
+func (u *AdminUsecase) GetAll(ctx context.Context) ([]admin.Admin, error) {
+	admins, err := u.repository.GetAll(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get admins")
+	}
+
+	return admins, nil
+}
+
+func (u *AdminUsecase) GetLockouts(ctx context.Context) ([]admin.LoginLockout, error) {
+	lockouts, err := u.lockoutRepository.GetRecent(ctx, recentLockoutsLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get lockouts")
+	}
+
+	return lockouts, nil
+}
+
+func (u *AdminUsecase) GetById(ctx context.Context, ID string) (*admin.Admin, error) {
+	account, err := u.repository.GetById(ctx, ID)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.NotFound("admin")
+		}
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get admin")
+	}
+
+	return account, nil
+}
+
+func (u *AdminUsecase) Disable(ctx context.Context, actorID, ID string) error {
+	if actorID == ID {
+		return errors.InvalidInput("cannot disable your own account")
+	}
+
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	if account.Disabled() {
+		return nil
+	}
+
+	if err := u.ensureAnotherOwner(ctx, account); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	account.DisabledAt = &now
+
+	if err := u.repository.Update(ctx, account); err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to disable admin")
+	}
+
+	return u.endSessions(ctx, ID)
+}
+
+func (u *AdminUsecase) Enable(ctx context.Context, ID string) error {
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	account.DisabledAt = nil
+
+	if err := u.repository.Update(ctx, account); err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to enable admin")
+	}
+
+	return nil
+}
+
+func (u *AdminUsecase) Delete(ctx context.Context, actorID, ID string) error {
+	if actorID == ID {
+		return errors.InvalidInput("cannot delete your own account")
+	}
+
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	if err := u.ensureAnotherOwner(ctx, account); err != nil {
+		return err
+	}
+
+	if err := u.repository.Delete(ctx, ID); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NotFound("admin")
+		}
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to delete admin")
+	}
+
+	return u.endSessions(ctx, ID)
+}
+
+func (u *AdminUsecase) UpdateRole(ctx context.Context, actorID, ID string, input *admin.InputUpdateRole) (*admin.Admin, error) {
+	if actorID == ID {
+		return nil, errors.InvalidInput("cannot change your own role")
+	}
+
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Role == input.Role {
+		return account, nil
+	}
+
+	if err := u.ensureAnotherOwner(ctx, account); err != nil {
+		return nil, err
+	}
+
+	account.Role = input.Role
+
+	if err := u.repository.Update(ctx, account); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to update admin role")
+	}
+
+	return account, nil
+}
+
+// endSessions drops every refresh token of the admin; access tokens already
+// issued expire on their own within the access token TTL
+func (u *AdminUsecase) endSessions(ctx context.Context, ID string) error {
+	if err := u.sessions.DeleteAdminSessions(ctx, ID); err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to end sessions")
+	}
+
+	return nil
+}
+
+// ensureAnotherOwner refuses to remove the last active owner, since only
+// owners can manage admins and the system would be locked out
+func (u *AdminUsecase) ensureAnotherOwner(ctx context.Context, account *admin.Admin) error {
+	if account.Role != admin.RoleOwner || account.Disabled() {
+		return nil
+	}
+
+	owners, err := u.repository.CountActiveByRole(ctx, admin.RoleOwner)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to count admins")
+	}
+
+	if owners <= 1 {
+		return errors.New(errors.ErrCodeInvalidStatus, "at least one active owner is required").WithStatus(http.StatusConflict)
+	}
+
+	return nil
+}
+
+func (u *AdminUsecase) ChangePassword(ctx context.Context, ID string, input *admin.InputChangePassword) error {
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(input.CurrentPassword)); err != nil {
+		return errors.New(errors.ErrCodeInvalidCredential, "current password is incorrect").WithStatus(http.StatusBadRequest)
+	}
+
+	if input.NewPassword == input.CurrentPassword {
+		return errors.Validation("new password must differ from the current password")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to hash password")
+	}
+
+	now := time.Now()
+	account.Password = string(hashedPassword)
+	account.MustChangePassword = false
+	account.PasswordChangedAt = &now
+
+	if err := u.repository.Update(ctx, account); err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to change password")
+	}
+
+	// every session ends, the caller's included: all devices log in again with
+	// the new password once their access token expires
+	return u.endSessions(ctx, ID)
+}
@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"context"
+	"distributed_system/internal/domain/admin"
+	"distributed_system/internal/domain/agents"
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyBytes         = 32
+	defaultAPIKeyTTL    = 90 * 24 * time.Hour
+	apiKeyTouchInterval = time.Minute
+	// apiKeyDisplayLength is how much of the key is kept to recognise it in listings
+	apiKeyDisplayLength = len(admin.APIKeyPrefix) + 6
+)
+
+func (u *AdminUsecase) CreateAPIKey(ctx context.Context, actorID string, input *admin.InputCreateAPIKey) (*admin.CreatedAPIKey, error) {
+	creator, err := u.activeAccount(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]admin.APIKeyScope, 0, len(input.Scopes))
+	for _, scope := range input.Scopes {
+		// a key never grants more than its creator could do
+		if !creator.Role.Can(scope.Permission) {
+			return nil, errors.New(errors.ErrCodeForbidden, "role does not grant the requested scope").
+				WithStatus(http.StatusForbidden).
+				WithDetails(string(scope.Permission))
+		}
+
+		namespaces := scope.Namespaces
+		if len(namespaces) == 0 {
+			namespaces = []string{agents.DefaultNamespace}
+		}
+
+		// agents and registration tokens are not kept per namespace, so a
+		// narrower agents:manage scope would not restrict anything
+		if scope.Permission == admin.PermManageAgents {
+			if len(scope.Namespaces) > 0 && (len(scope.Namespaces) != 1 || scope.Namespaces[0] != admin.AnyNamespace) {
+				return nil, errors.Validation(`agents:manage scopes must use namespace "*"`)
+			}
+			namespaces = []string{admin.AnyNamespace}
+		}
+
+		scopes = append(scopes, admin.APIKeyScope{Permission: scope.Permission, Namespaces: namespaces})
+	}
+
+	random, err := crypto.RandomToken(apiKeyBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to create api key")
+	}
+	plain := admin.APIKeyPrefix + random
+
+	ttl := defaultAPIKeyTTL
+	if input.ExpiresIn > 0 {
+		ttl = time.Duration(input.ExpiresIn) * time.Second
+	}
+
+	now := time.Now()
+
+	key := admin.APIKey{
+		UUID:      uuid.New().String(),
+		Name:      strings.TrimSpace(input.Name),
+		KeyPrefix: plain[:apiKeyDisplayLength],
+		KeyHash:   crypto.HashToken(plain),
+		Scopes:    scopes,
+		CreatedBy: creator.UUID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now.Format(time.RFC3339),
+	}
+
+	if err := u.apiKeyRepository.Create(ctx, &key); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to create api key")
+	}
+
+	return &admin.CreatedAPIKey{Key: plain, APIKey: key}, nil
+}
+
+func (u *AdminUsecase) GetAPIKeys(ctx context.Context) ([]admin.APIKey, error) {
+	keys, err := u.apiKeyRepository.GetAll(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get api keys")
+	}
+
+	return keys, nil
+}
+
+func (u *AdminUsecase) RevokeAPIKey(ctx context.Context, ID string) error {
+	if err := u.apiKeyRepository.Revoke(ctx, ID, time.Now()); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NotFound("api key")
+		}
+
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to revoke api key")
+	}
+
+	return nil
+}
+
+func (u *AdminUsecase) AuthenticateAPIKey(ctx context.Context, plain string) (*admin.APIKey, error) {
+	if !strings.HasPrefix(plain, admin.APIKeyPrefix) {
+		return nil, errors.ErrInvalidToken.Clone()
+	}
+
+	key, err := u.apiKeyRepository.GetByHash(ctx, crypto.HashToken(plain))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.ErrInvalidToken.Clone()
+		}
+
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get api key")
+	}
+
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, errors.New(errors.ErrCodeInvalidToken, "api key is expired or revoked").
+			WithStatus(http.StatusUnauthorized)
+	}
+
+	creator, err := u.activeAccount(ctx, key.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	// scopes the creator lost since (role change) stop working as well
+	for _, scope := range key.Scopes {
+		if !creator.Role.Can(scope.Permission) {
+			return nil, errors.New(errors.ErrCodeUnauthorized, "api key creator no longer holds its scopes").
+				WithStatus(http.StatusUnauthorized)
+		}
+	}
+
+	if err := u.apiKeyRepository.Touch(ctx, key.UUID, now, apiKeyTouchInterval); err != nil {
+		log.Printf("[Auth] Failed to update last use of api key %s: %v", key.UUID, err)
+	}
+
+	return key, nil
+}
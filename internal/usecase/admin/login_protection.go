@@ -0,0 +1,112 @@
+package admin
+
+import (
+	"context"
+	"distributed_system/internal/domain/admin"
+	"distributed_system/pkg/errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recentLockoutsLimit = 100
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash is a bcrypt hash no password matches, used to spend the
+// same time on unknown emails as on real accounts
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte(uuid.New().String()), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
+
+// checkLockout rejects logins from a locked IP or for a locked account. Unknown
+// emails are counted and locked like real ones so lockouts reveal nothing.
+func (u *AdminUsecase) checkLockout(ctx context.Context, email, ip string) error {
+	for _, scope := range []struct{ name, subject string }{
+		{admin.LockoutScopeIP, ip},
+		{admin.LockoutScopeAccount, email},
+	} {
+		if scope.subject == "" {
+			continue
+		}
+
+		remaining, err := u.attempts.LockedFor(ctx, scope.name, scope.subject)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrCodeInternal, "failed to check login attempts")
+		}
+
+		if remaining > 0 {
+			return errors.New(errors.ErrCodeRateLimit, "too many failed login attempts").
+				WithStatus(http.StatusTooManyRequests).
+				WithDetails(fmt.Sprintf("try again in %s", remaining.Round(time.Second)))
+		}
+	}
+
+	return nil
+}
+
+// recordFailure counts a failed login against the IP and the account and locks
+// whichever reached its limit
+func (u *AdminUsecase) recordFailure(ctx context.Context, email, ip string) error {
+	limits := u.cfg.Security.Login
+
+	for _, scope := range []struct {
+		name, subject string
+		max           int64
+	}{
+		{admin.LockoutScopeIP, ip, limits.MaxIPAttempts},
+		{admin.LockoutScopeAccount, email, limits.MaxAccountAttempts},
+	} {
+		if scope.subject == "" || scope.max <= 0 {
+			continue
+		}
+
+		failures, err := u.attempts.RecordFailure(ctx, scope.name, scope.subject, limits.Window)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrCodeInternal, "failed to record login attempt")
+		}
+
+		if failures < scope.max {
+			continue
+		}
+
+		duration, err := u.attempts.Lock(ctx, scope.name, scope.subject, limits.LockoutBase, limits.LockoutMax)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrCodeInternal, "failed to lock login")
+		}
+
+		u.recordLockout(ctx, scope.name, scope.subject, ip, failures, duration)
+	}
+
+	return nil
+}
+
+func (u *AdminUsecase) recordLockout(ctx context.Context, scope, subject, ip string, attempts int64, duration time.Duration) {
+	now := time.Now()
+
+	log.Printf("[Auth] Login locked: %s=%s ip=%s attempts=%d duration=%s", scope, subject, ip, attempts, duration)
+
+	// the lockout is already active in Redis; a failed write only loses the record
+	if err := u.lockoutRepository.Create(ctx, &admin.LoginLockout{
+		UUID:        uuid.New().String(),
+		Scope:       scope,
+		Subject:     subject,
+		IPAddress:   ip,
+		Attempts:    attempts,
+		LockedUntil: now.Add(duration),
+		CreatedAt:   now.Format(time.RFC3339),
+	}); err != nil {
+		log.Printf("[Auth] Failed to record login lockout: %v", err)
+	}
+}
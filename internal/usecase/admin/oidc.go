@@ -0,0 +1,242 @@
+package admin
+
+import (
+	"context"
+	"distributed_system/internal/config"
+	"distributed_system/internal/domain/admin"
+	"distributed_system/internal/infrastructure/cache"
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/errors"
+	"distributed_system/pkg/oidc"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// oidcStateTTL is how long the user has to sign in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+// newOIDCProvider returns nil when single sign-on is disabled
+func newOIDCProvider(cfg *config.OIDCConfig) *oidc.Provider {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       cfg.Issuer,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       cfg.Scopes,
+	})
+}
+
+func errSSODisabled() error {
+	return errors.New(errors.ErrCodeNotFound, "single sign-on is not enabled").WithStatus(http.StatusNotFound)
+}
+
+// errSSODenied hides why a sign-in was refused; the reason is logged instead
+func errSSODenied(reason string, args ...interface{}) error {
+	log.Printf("[Auth] OIDC login denied: "+reason, args...)
+	return errors.New(errors.ErrCodeUnauthorized, "single sign-on failed").WithStatus(http.StatusUnauthorized)
+}
+
+func (u *AdminUsecase) StartOIDCLogin(ctx context.Context) (string, error) {
+	if u.oidc == nil {
+		return "", errSSODisabled()
+	}
+
+	req, err := u.oidc.AuthCodeURL(ctx)
+	if err != nil {
+		log.Printf("[Auth] OIDC provider unavailable: %v", err)
+		return "", errors.New(errors.ErrCodeExternalService, "identity provider is unavailable").WithStatus(http.StatusServiceUnavailable)
+	}
+
+	state := &cache.OIDCState{Nonce: req.Nonce, Verifier: req.Verifier}
+	if err := u.sessions.StoreOIDCState(ctx, crypto.HashToken(req.State), state, oidcStateTTL); err != nil {
+		return "", errors.Wrap(err, errors.ErrCodeInternal, "failed to store login state")
+	}
+
+	return req.URL, nil
+}
+
+func (u *AdminUsecase) LoginOIDC(ctx context.Context, input *admin.InputOIDCCallback) (*admin.LoginResult, error) {
+	if u.oidc == nil {
+		return nil, errSSODisabled()
+	}
+
+	if err := u.checkLockout(ctx, "", input.IP); err != nil {
+		return nil, err
+	}
+
+	state, err := u.sessions.ConsumeOIDCState(ctx, crypto.HashToken(input.State))
+	if err != nil {
+		if errors.Is(err, cache.ErrSessionNotFound) {
+			return nil, errors.ErrInvalidToken.Clone()
+		}
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to read login state")
+	}
+
+	claims, err := u.oidc.Exchange(ctx, input.Code, state.Verifier, state.Nonce)
+	if err != nil {
+		return nil, errSSODenied("%v", err)
+	}
+
+	account, err := u.oidcAccount(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.TOTPEnabled() {
+		return u.startMFAChallenge(ctx, account)
+	}
+
+	return u.issueSession(ctx, account)
+}
+
+// oidcAccount maps a verified identity to a local admin: by the linked
+// subject, else by a verified email (linking it), else by provisioning a new
+// admin. The role rules are applied on every sign-in so the provider stays
+// authoritative.
+func (u *AdminUsecase) oidcAccount(ctx context.Context, claims jwt.MapClaims) (*admin.Admin, error) {
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errSSODenied("id token has no subject")
+	}
+
+	role, ok := u.oidcRole(claims)
+	if !ok {
+		return nil, errSSODenied("no role rule matches %s", subject)
+	}
+
+	email, _ := claims[u.cfg.Security.OIDC.EmailClaim].(string)
+	email = normalizeEmail(email)
+	// an address the provider did not vouch for could belong to anyone, so
+	// such identities only match by issuer and subject
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		email = ""
+	}
+
+	account, err := u.repository.GetByOIDCSubject(ctx, issuer, subject)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get admin")
+	}
+
+	if account == nil && email != "" {
+		account, err = u.repository.GetByEmail(ctx, email)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get admin")
+		}
+		if account != nil && account.OIDCSubject != "" {
+			return nil, errSSODenied("%s is already linked to another identity", email)
+		}
+	}
+
+	if account == nil {
+		if !u.cfg.Security.OIDC.AutoProvision || email == "" {
+			return nil, errSSODenied("no admin account for %s", subject)
+		}
+		return u.provisionOIDCAccount(ctx, issuer, subject, email, role)
+	}
+
+	if account.Disabled() {
+		return nil, errors.ErrInvalidCredentials.Clone()
+	}
+
+	changed := account.OIDCSubject == ""
+	account.OIDCIssuer = issuer
+	account.OIDCSubject = subject
+
+	if account.Role != role {
+		if role != admin.RoleOwner {
+			if err := u.ensureAnotherOwner(ctx, account); err != nil {
+				return nil, err
+			}
+		}
+		account.Role = role
+		changed = true
+	}
+
+	if changed {
+		if err := u.repository.Update(ctx, account); err != nil {
+			return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to update admin")
+		}
+	}
+
+	return account, nil
+}
+
+// provisionOIDCAccount creates an admin that signs in through the provider
+// only; its random password is never handed out
+func (u *AdminUsecase) provisionOIDCAccount(ctx context.Context, issuer, subject, email string, role admin.Role) (*admin.Admin, error) {
+	password, err := crypto.RandomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to create admin")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to hash password")
+	}
+
+	account := &admin.Admin{
+		UUID:              uuid.New().String(),
+		Email:             email,
+		Password:          string(hashedPassword),
+		Role:              role,
+		OIDCIssuer:        issuer,
+		OIDCSubject:       subject,
+		TOTPRecoveryCodes: []string{},
+		CreatedAt:         time.Now().Format(time.RFC3339),
+	}
+
+	if err := u.repository.Create(ctx, account); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to create admin")
+	}
+
+	log.Printf("[Auth] Provisioned admin %s (%s) from single sign-on", email, role)
+	return account, nil
+}
+
+// oidcRole returns the role of the first matching rule, else the default role
+func (u *AdminUsecase) oidcRole(claims jwt.MapClaims) (admin.Role, bool) {
+	for _, rule := range u.cfg.Security.OIDC.RoleRules {
+		if !claimMatches(claims[rule.Claim], rule.Value) {
+			continue
+		}
+
+		role := admin.Role(rule.Role)
+		if !role.Valid() {
+			log.Printf("[Auth] Ignoring OIDC role rule with unknown role %q", rule.Role)
+			continue
+		}
+		return role, true
+	}
+
+	role := admin.Role(u.cfg.Security.OIDC.DefaultRole)
+	return role, role.Valid()
+}
+
+// claimMatches compares a claim to the rule value; list claims (groups, roles)
+// match when any element does
+func claimMatches(claim interface{}, value string) bool {
+	switch v := claim.(type) {
+	case nil:
+		return false
+	case []interface{}:
+		for _, item := range v {
+			if claimMatches(item, value) {
+				return true
+			}
+		}
+		return false
+	default:
+		return fmt.Sprint(v) == value
+	}
+}
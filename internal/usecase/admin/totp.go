@@ -0,0 +1,255 @@
+package admin
+
+import (
+	"context"
+	"distributed_system/internal/domain/admin"
+	"distributed_system/internal/infrastructure/cache"
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	mfaTokenBytes     = 32
+	recoveryCodeCount = 10
+	// accept the previous and next 30s step for clock drift
+	totpSkew = 1
+)
+
+func (u *AdminUsecase) startMFAChallenge(ctx context.Context, account *admin.Admin) (*admin.LoginResult, error) {
+	mfaToken, err := crypto.RandomToken(mfaTokenBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to generate 2FA challenge")
+	}
+
+	if err := u.sessions.StoreMFAChallenge(ctx, crypto.HashToken(mfaToken), account.UUID, mfaChallengeTTL); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to store 2FA challenge")
+	}
+
+	return &admin.LoginResult{
+		MustChangePassword: account.MustChangePassword,
+		MFARequired:        true,
+		MFAToken:           mfaToken,
+	}, nil
+}
+
+// LoginMFA finishes the second login step. The challenge is consumed before
+// the code is checked, so it allows a single attempt and cannot mint two
+// sessions; wrong codes also count as failed logins for the account, so the
+// regular lockout stops code guessing.
+func (u *AdminUsecase) LoginMFA(ctx context.Context, input *admin.InputLoginMFA) (*admin.LoginResult, error) {
+	adminID, err := u.sessions.ConsumeMFAChallenge(ctx, crypto.HashToken(input.MFAToken))
+	if err != nil {
+		if errors.Is(err, cache.ErrSessionNotFound) {
+			return nil, errors.ErrInvalidToken.Clone()
+		}
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to read 2FA challenge")
+	}
+
+	account, err := u.activeAccount(ctx, adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.checkLockout(ctx, account.Email, input.IP); err != nil {
+		return nil, err
+	}
+
+	var verified bool
+	if input.Code != "" {
+		verified = u.acceptTOTP(account, input.Code)
+	} else {
+		verified = useRecoveryCode(account, input.RecoveryCode)
+	}
+
+	if !verified {
+		if err := u.recordFailure(ctx, account.Email, input.IP); err != nil {
+			return nil, err
+		}
+		return nil, errors.ErrInvalidCredentials.Clone()
+	}
+
+	// persists the consumed step or recovery code
+	if err := u.repository.Update(ctx, account); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to update admin")
+	}
+
+	if err := u.attempts.Reset(ctx, admin.LockoutScopeAccount, account.Email); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to reset login attempts")
+	}
+
+	return u.issueSession(ctx, account)
+}
+
+func (u *AdminUsecase) EnrollTOTP(ctx context.Context, ID string) (*admin.TOTPEnrollment, error) {
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.TOTPEnabled() {
+		return nil, errors.New(errors.ErrCodeInvalidStatus, "two-factor authentication is already enabled").WithStatus(http.StatusConflict)
+	}
+
+	secret, err := crypto.GenerateTOTPSecret()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to generate TOTP secret")
+	}
+
+	account.TOTPSecret = secret
+	account.TOTPLastStep = 0
+
+	if err := u.repository.Update(ctx, account); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to store TOTP secret")
+	}
+
+	return &admin.TOTPEnrollment{
+		Secret: secret,
+		URI:    crypto.TOTPURI(u.cfg.Security.TOTPIssuer, account.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP turns 2FA on once the admin proves the authenticator works and
+// hands out the recovery codes, which are only shown this once
+func (u *AdminUsecase) ConfirmTOTP(ctx context.Context, ID string, input *admin.InputTOTPCode) (*admin.RecoveryCodes, error) {
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.TOTPEnabled() {
+		return nil, errors.New(errors.ErrCodeInvalidStatus, "two-factor authentication is already enabled").WithStatus(http.StatusConflict)
+	}
+	if account.TOTPSecret == "" {
+		return nil, errors.New(errors.ErrCodeInvalidStatus, "start enrollment first").WithStatus(http.StatusConflict)
+	}
+
+	if !u.acceptTOTP(account, input.Code) {
+		return nil, errors.Validation("invalid authentication code")
+	}
+
+	codes, err := resetRecoveryCodes(account)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	account.TOTPEnabledAt = &now
+
+	if err := u.repository.Update(ctx, account); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to enable two-factor authentication")
+	}
+
+	return codes, nil
+}
+
+func (u *AdminUsecase) DisableTOTP(ctx context.Context, ID string, input *admin.InputDisableTOTP) error {
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return err
+	}
+
+	if !account.TOTPEnabled() {
+		return nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(account.Password), []byte(input.Password)); err != nil {
+		return errors.New(errors.ErrCodeInvalidCredential, "password is incorrect").WithStatus(http.StatusBadRequest)
+	}
+
+	if !u.acceptTOTP(account, input.Code) && !useRecoveryCode(account, input.Code) {
+		return errors.Validation("invalid authentication code")
+	}
+
+	account.TOTPSecret = ""
+	account.TOTPEnabledAt = nil
+	account.TOTPLastStep = 0
+	account.TOTPRecoveryCodes = []string{}
+
+	if err := u.repository.Update(ctx, account); err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to disable two-factor authentication")
+	}
+
+	return nil
+}
+
+func (u *AdminUsecase) RegenerateRecoveryCodes(ctx context.Context, ID string, input *admin.InputTOTPCode) (*admin.RecoveryCodes, error) {
+	account, err := u.GetById(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !account.TOTPEnabled() {
+		return nil, errors.New(errors.ErrCodeInvalidStatus, "two-factor authentication is not enabled").WithStatus(http.StatusConflict)
+	}
+
+	if !u.acceptTOTP(account, input.Code) {
+		return nil, errors.Validation("invalid authentication code")
+	}
+
+	codes, err := resetRecoveryCodes(account)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.repository.Update(ctx, account); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to store recovery codes")
+	}
+
+	return codes, nil
+}
+
+// acceptTOTP validates a code and records its step so the same code cannot be
+// replayed; the caller persists the account
+func (u *AdminUsecase) acceptTOTP(account *admin.Admin, code string) bool {
+	step, ok := crypto.ValidateTOTP(account.TOTPSecret, code, time.Now(), totpSkew)
+	if !ok || step <= account.TOTPLastStep {
+		return false
+	}
+
+	account.TOTPLastStep = step
+	return true
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// useRecoveryCode removes a matching recovery code; the caller persists the account
+func useRecoveryCode(account *admin.Admin, code string) bool {
+	hash := crypto.HashToken(normalizeRecoveryCode(code))
+
+	for i, stored := range account.TOTPRecoveryCodes {
+		if stored == hash {
+			account.TOTPRecoveryCodes = append(account.TOTPRecoveryCodes[:i], account.TOTPRecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// resetRecoveryCodes replaces the stored hashes and returns the plaintext codes
+func resetRecoveryCodes(account *admin.Admin) (*admin.RecoveryCodes, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := crypto.GenerateTOTPSecret()
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to generate recovery codes")
+		}
+
+		code := strings.ToLower(raw[:5] + "-" + raw[5:10])
+		codes = append(codes, code)
+		hashes = append(hashes, crypto.HashToken(normalizeRecoveryCode(code)))
+	}
+
+	account.TOTPRecoveryCodes = hashes
+	return &admin.RecoveryCodes{RecoveryCodes: codes}, nil
+}
@@ -4,48 +4,427 @@ import (
 	"context"
 	"distributed_system/internal/config"
 	"distributed_system/internal/domain/agents"
+	"distributed_system/internal/domain/webhook"
 	"distributed_system/pkg/crypto"
 	"distributed_system/pkg/errors"
+	"distributed_system/pkg/pki"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultRegistrationTokenTTL = 24 * time.Hour
+	defaultAgentCredentialTTL   = 30 * 24 * time.Hour
+	defaultClientCertTTL        = 7 * 24 * time.Hour
+	registrationTokenBytes      = 32
 )
 
 type AgentUsecase struct {
-	repository agents.Repostiory
-	cfg        *config.Config
+	repository      agents.Repostiory
+	tokenRepository agents.RegistrationTokenRepository
+	cfg             *config.Config
+	// ca signs agent client certificates, nil when TLS is disabled
+	ca *pki.CA
+	// configSigner is the config signing key, handed to agents with their credential
+	configSigner *crypto.Ed25519Signer
+	events       webhook.Publisher
 }
 
-func NewAgentUsecase(repository agents.Repostiory, cfg *config.Config) agents.Usecase {
-	return &AgentUsecase{repository: repository, cfg: cfg}
+func NewAgentUsecase(repository agents.Repostiory, tokenRepository agents.RegistrationTokenRepository, cfg *config.Config, ca *pki.CA, configSigner *crypto.Ed25519Signer, events webhook.Publisher) agents.Usecase {
+	return &AgentUsecase{repository: repository, tokenRepository: tokenRepository, cfg: cfg, ca: ca, configSigner: configSigner, events: events}
 }
 
-func (u *AgentUsecase) Create(ctx context.Context) (string, error) {
+func (u *AgentUsecase) Create(ctx context.Context, input *agents.InputRegister, token *agents.RegistrationToken) (*agents.Credential, error) {
+	if u.ca != nil && input.CSR == "" {
+		return nil, errors.Validation("csr is required when mTLS is enabled")
+	}
+
 	now := time.Now().Format(time.RFC3339)
 
+	workerURLs := input.WorkerURLs
+	if workerURLs == nil {
+		workerURLs = []string{}
+	}
+
+	// labels pre-assigned on the token win over what the agent reports
+	labels := map[string]string{}
+	for k, v := range input.Labels {
+		labels[k] = v
+	}
+	for k, v := range token.Labels {
+		labels[k] = v
+	}
+
+	namespaces := token.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{agents.DefaultNamespace}
+	}
+
 	agent := &agents.Agent{
+		UUID:         uuid.New().String(),
+		Hostname:     input.Hostname,
+		IPAddress:    input.IPAddress,
+		OS:           input.OS,
+		Arch:         input.Arch,
+		AgentVersion: input.AgentVersion,
+		WorkerURLs:   workerURLs,
+		Labels:       labels,
+		Environment:  token.Environment,
+		Namespaces:   namespaces,
+		CreatedAt:    now,
+
+		RegistrationTokenID: &token.UUID,
+	}
+
+	credential, err := u.issueCredential(agent, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	agent.CredentialGeneration = credential.Generation
+	agent.CredentialExpiresAt = &credential.ExpiresAt
+
+	if u.ca != nil {
+		certificate, err := u.signCertificate(agent, input.CSR)
+		if err != nil {
+			return nil, err
+		}
+		credential.Certificate = certificate
+	}
+
+	if err := u.repository.Enroll(ctx, agent, time.Now()); err != nil {
+		return nil, err
+	}
+
+	u.events.Publish(ctx, webhook.EventAgentRegistered, agent)
+
+	return credential, nil
+}
+
+func (u *AgentUsecase) RotateCredential(ctx context.Context, agent *agents.Agent) (*agents.Credential, error) {
+	credential, err := u.issueCredential(agent, agent.CredentialGeneration+1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.repository.UpdateCredential(ctx, agent.UUID, agent.CredentialGeneration, credential.Generation, credential.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+func (u *AgentUsecase) RenewCertificate(ctx context.Context, agent *agents.Agent, input *agents.InputRenewCertificate) (*agents.Certificate, error) {
+	if u.ca == nil {
+		return nil, errors.New(errors.ErrCodeNotAvailable, "mTLS is not enabled").WithStatus(http.StatusNotFound)
+	}
+
+	return u.signCertificate(agent, input.CSR)
+}
+
+// signCertificate issues a client certificate whose common name is the agent id
+func (u *AgentUsecase) signCertificate(agent *agents.Agent, csr string) (*agents.Certificate, error) {
+	ttl := u.cfg.TLS.ClientCertTTL
+	if ttl <= 0 {
+		ttl = defaultClientCertTTL
+	}
+
+	certPEM, expiresAt, err := u.ca.SignCSR([]byte(csr), agent.UUID, ttl)
+	if err != nil {
+		return nil, errors.InvalidInput("invalid csr").WithDetails(err.Error())
+	}
+
+	return &agents.Certificate{
+		Certificate:   string(certPEM),
+		CACertificate: string(u.ca.CertPEM),
+		ExpiresAt:     expiresAt,
+	}, nil
+}
+
+func (u *AgentUsecase) RevokeCredential(ctx context.Context, ID string) error {
+	if err := u.repository.RevokeCredential(ctx, ID, time.Now()); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NotFound("agent")
+		}
+
+		return errors.Wrap(err, "agent", "failed to revoke agent credential")
+	}
+
+	return nil
+}
+
+func (u *AgentUsecase) ValidateCredential(ctx context.Context, credential string) (*agents.Agent, *agents.CredentialClaims, error) {
+	claims := &agents.CredentialClaims{}
+
+	_, err := u.cfg.Security.AgentKeyring.ParseJWT(credential, claims,
+		jwt.WithIssuer(agents.CredentialIssuer),
+		jwt.WithAudience(agents.CredentialAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, nil, errors.ErrTokenExpired.Clone()
+		}
+		if !errors.Is(err, jwt.ErrTokenMalformed) {
+			return nil, nil, errors.ErrInvalidToken.Clone()
+		}
+
+		// not a JWT at all, fall back to the HMAC format during the migration window
+		claims, err = u.parseLegacyCredential(credential)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	agent, err := u.repository.GetById(ctx, claims.Subject)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, errors.ErrInvalidToken.Clone()
+		}
+
+		return nil, nil, errors.Wrap(err, "agent", "failed to get agent")
+	}
+
+	if agent.CredentialRevokedAt != nil {
+		return nil, nil, errors.New(errors.ErrCodeInvalidCredential, "agent credential has been revoked").
+			WithStatus(http.StatusUnauthorized)
+	}
+
+	if claims.Generation != agent.CredentialGeneration {
+		return nil, nil, errors.New(errors.ErrCodeInvalidCredential, "agent credential has been superseded").
+			WithStatus(http.StatusUnauthorized)
+	}
+
+	if claims.Legacy {
+		until := "security.legacy_agent_tokens_until is set"
+		if deadline := u.cfg.Security.LegacyAgentTokenDeadline; !deadline.IsZero() {
+			until = deadline.Format(time.RFC3339)
+		}
+		log.Printf("[Agent] Warning: agent %s (%s) used a legacy credential, accepted until %s",
+			agent.UUID, agent.Hostname, until)
+
+		claims.Environment = agent.Environment
+		claims.Namespaces = agent.Namespaces
+	}
+
+	if claims.Environment != agent.Environment {
+		return nil, nil, errors.New(errors.ErrCodeInvalidCredential, "agent credential environment mismatch").
+			WithStatus(http.StatusUnauthorized)
+	}
+
+	return agent, claims, nil
+}
+
+// parseLegacyCredential verifies a base64(payload).base64(hmac) credential and
+// turns its payload into claims
+func (u *AgentUsecase) parseLegacyCredential(credential string) (*agents.CredentialClaims, error) {
+	// legacy credentials are accepted until the migration window is closed
+	// with an explicit deadline
+	deadline := u.cfg.Security.LegacyAgentTokenDeadline
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return nil, errors.New(errors.ErrCodeInvalidToken, "legacy agent credentials are no longer accepted").
+			WithStatus(http.StatusUnauthorized)
+	}
+
+	isValid, payload, err := u.cfg.Security.AgentKeyring.Verify(credential)
+	if err != nil || !isValid {
+		return nil, errors.ErrInvalidToken.Clone()
+	}
+
+	agentID, generation, expiresAt, err := parseCredentialPayload(payload)
+	if err != nil {
+		return nil, errors.ErrInvalidToken.Clone()
+	}
+
+	if expiresAt != nil && time.Now().After(*expiresAt) {
+		return nil, errors.ErrTokenExpired.Clone()
+	}
+
+	return &agents.CredentialClaims{
+		Generation: generation,
+		Legacy:     true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: agentID,
+		},
+	}, nil
+}
+
+// issueCredential signs a credential JWT for the agent with the active agent key
+func (u *AgentUsecase) issueCredential(agent *agents.Agent, generation int) (*agents.Credential, error) {
+	ttl := u.cfg.Security.AgentCredentialTTL
+	if ttl <= 0 {
+		ttl = defaultAgentCredentialTTL
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	expiresAt := now.Add(ttl)
+
+	claims := &agents.CredentialClaims{
+		Environment: agent.Environment,
+		Namespaces:  agent.Namespaces,
+		Generation:  generation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    agents.CredentialIssuer,
+			Subject:   agent.UUID,
+			Audience:  jwt.ClaimStrings{agents.CredentialAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        uuid.New().String(),
+		},
+	}
+
+	signed, err := u.cfg.Security.AgentKeyring.SignJWT(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, "agent", "failed to create access token")
+	}
+
+	return &agents.Credential{
+		AgentID:           agent.UUID,
+		Credential:        signed,
+		Generation:        generation,
+		ExpiresAt:         expiresAt,
+		ConfigSigningKeys: []string{u.configSigner.PublicKey()},
+	}, nil
+}
+
+// parseCredentialPayload accepts both HMAC payload formats: "<id>:<generation>:<expiry>"
+// and the original bare agent id, which maps to generation 0 with no expiry
+func parseCredentialPayload(payload string) (string, int, *time.Time, error) {
+	parts := strings.Split(payload, ":")
+	if len(parts) == 1 {
+		return parts[0], 0, nil, nil
+	}
+
+	if len(parts) != 3 {
+		return "", 0, nil, fmt.Errorf("invalid credential payload")
+	}
+
+	generation, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("invalid credential generation: %w", err)
+	}
+
+	unix, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("invalid credential expiry: %w", err)
+	}
+
+	expiresAt := time.Unix(unix, 0)
+	return parts[0], generation, &expiresAt, nil
+}
+
+func (u *AgentUsecase) CreateRegistrationToken(ctx context.Context, input *agents.InputRegistrationToken) (*agents.CreatedRegistrationToken, error) {
+	plain, err := crypto.RandomToken(registrationTokenBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "agent", "failed to create registration token")
+	}
+
+	maxUses := input.MaxUses
+	if maxUses == 0 {
+		maxUses = 1
+	}
+
+	ttl := defaultRegistrationTokenTTL
+	if input.ExpiresIn > 0 {
+		ttl = time.Duration(input.ExpiresIn) * time.Second
+	}
+
+	labels := input.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	namespaces := input.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{agents.DefaultNamespace}
+	}
+
+	now := time.Now()
+
+	token := agents.RegistrationToken{
 		UUID:        uuid.New().String(),
-		CreatedAt: now,
+		TokenHash:   crypto.HashToken(plain),
+		Description: input.Description,
+		Environment: input.Environment,
+		Labels:      labels,
+		Namespaces:  namespaces,
+		MaxUses:     maxUses,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now.Format(time.RFC3339),
 	}
 
-	if err := u.repository.Create(ctx, agent); err != nil {
-		return "", errors.Wrap(err, "agent", "failed to create agent")
+	if err := u.tokenRepository.Create(ctx, &token); err != nil {
+		return nil, errors.Wrap(err, "agent", "failed to create registration token")
 	}
 
-	tokenAccessConfig, err := crypto.Generate(agent.UUID, u.cfg.Security.AgentSig)
+	return &agents.CreatedRegistrationToken{Token: plain, RegistrationToken: token}, nil
+}
+
+func (u *AgentUsecase) GetRegistrationTokens(ctx context.Context) ([]agents.RegistrationToken, error) {
+	tokens, err := u.tokenRepository.GetAll(ctx)
 	if err != nil {
-		return "", errors.Wrap(err, "agent", "failed to create access token")
+		return nil, errors.Wrap(err, "agent", "failed to get registration tokens")
 	}
 
-	return tokenAccessConfig, nil
+	return tokens, nil
 }
 
-func (u *AgentUsecase) CreateRegistrationToken(ctx context.Context) (string, error) {
-	token, err := bcrypt.GenerateFromPassword([]byte(u.cfg.Security.AgentSecret), 10)
+func (u *AgentUsecase) RevokeRegistrationToken(ctx context.Context, ID string) error {
+	if err := u.tokenRepository.Revoke(ctx, ID, time.Now()); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NotFound("registration token")
+		}
+
+		return errors.Wrap(err, "agent", "failed to revoke registration token")
+	}
+
+	return nil
+}
+
+func (u *AgentUsecase) ValidateRegistrationToken(ctx context.Context, token string) (*agents.RegistrationToken, error) {
+	registrationToken, err := u.tokenRepository.GetByHash(ctx, crypto.HashToken(token))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.ErrInvalidToken.Clone()
+		}
+
+		return nil, errors.Wrap(err, "agent", "failed to get registration token")
+	}
+
+	if !registrationToken.Usable(time.Now()) {
+		return nil, errors.New(errors.ErrCodeInvalidToken, "registration token is expired, revoked or used up").
+			WithStatus(http.StatusUnauthorized)
+	}
+
+	return registrationToken, nil
+}
+
+func (u *AgentUsecase) GetAll(ctx context.Context) ([]agents.Agent, error) {
+	list, err := u.repository.GetAll(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "agent", "failed to get agents")
+	}
+
+	return list, nil
+}
+
+func (u *AgentUsecase) GetById(ctx context.Context, ID string) (*agents.Agent, error) {
+	agent, err := u.repository.GetById(ctx, ID)
 	if err != nil {
-		return "", errors.Wrap(err, "agent", "failed to create registration token")
+		if errors.IsNotFound(err) {
+			return nil, errors.NotFound("agent")
+		}
+
+		return nil, errors.Wrap(err, "agent", "failed to get agent")
 	}
 
-	return string(token), nil
-}
\ No newline at end of file
+	return agent, nil
+}
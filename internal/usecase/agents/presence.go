@@ -0,0 +1,47 @@
+package agents
+
+import (
+	"context"
+	"distributed_system/internal/domain/webhook"
+	"log"
+	"time"
+)
+
+// minPresenceCheck bounds how often MonitorOffline queries the database
+const minPresenceCheck = 15 * time.Second
+
+func (u *AgentUsecase) MonitorOffline(ctx context.Context) {
+	after := u.cfg.Webhooks.AgentOfflineAfter
+	if after <= 0 {
+		return
+	}
+
+	interval := after / 4
+	if interval < minPresenceCheck {
+		interval = minPresenceCheck
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		offline, err := u.repository.MarkOffline(ctx, now.Add(-after), now)
+		if err != nil {
+			log.Printf("[Agent] Failed to check for offline agents: %v", err)
+			continue
+		}
+
+		for i := range offline {
+			agent := &offline[i]
+			log.Printf("[Agent] Agent %s (%s) is offline, last seen %s", agent.UUID, agent.Hostname, agent.LastSeenAt.Format(time.RFC3339))
+			u.events.Publish(ctx, webhook.EventAgentOffline, agent)
+		}
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"distributed_system/internal/domain/agents"
 	"distributed_system/internal/domain/config"
+	"distributed_system/internal/domain/webhook"
 	"distributed_system/internal/infrastructure/cache"
+	"distributed_system/pkg/crypto"
 	"distributed_system/pkg/errors"
 	"fmt"
+	"log"
+	"net/http"
 	"time"
 
 	configEnv "distributed_system/internal/config"
@@ -19,18 +23,42 @@ type ConfigUsecase struct {
 	agentsRepository agents.Repostiory
 	cfg        *configEnv.Config
 	cache      *cache.ConfigCache
+	signer     *crypto.Ed25519Signer
+	verifier   *crypto.Ed25519Verifier
+	events     webhook.Publisher
 }
 
-func NewConfigUsecase(repository config.Repository, agentRespository agents.Repostiory, cfg *configEnv.Config, cache *cache.ConfigCache) config.Usecase {
+// agentSeenInterval limits how often an agent's poll is written to the database
+const agentSeenInterval = 30 * time.Second
+
+func NewConfigUsecase(repository config.Repository, agentRespository agents.Repostiory, cfg *configEnv.Config, cache *cache.ConfigCache, signer *crypto.Ed25519Signer, events webhook.Publisher) config.Usecase {
+	// the controller checks its own cache entries so a tampered Redis value is
+	// never handed to agents with a fresh signature
+	verifier, _ := crypto.NewEd25519Verifier([]string{signer.PublicKey()})
+
 	return &ConfigUsecase{
 		repository: repository,
 		agentsRepository: agentRespository,
 		cfg: cfg,
 		cache: cache,
+		signer: signer,
+		verifier: verifier,
+		events: events,
+	}
+}
+
+func (u *ConfigUsecase) sign(cfg *config.Config) error {
+	payload, err := cfg.SignedPayload().Canonical()
+	if err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to encode config")
 	}
+
+	cfg.Signature = u.signer.Sign(payload)
+	cfg.SignatureKeyID = u.signer.KeyID()
+	return nil
 }
 
-func (u *ConfigUsecase) GetLatestConfig(ctx context.Context, agentID *string) (*config.Config, error) {
+func (u *ConfigUsecase) GetLatestConfig(ctx context.Context, namespace string, agentID *string) (*config.Config, error) {
 	if agentID != nil {
 		_, err := u.agentsRepository.GetById(ctx, *agentID)
 		if err != nil {
@@ -40,14 +68,22 @@ func (u *ConfigUsecase) GetLatestConfig(ctx context.Context, agentID *string) (*
 
 			return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get agent")
 		}
+
+		// every poll doubles as a heartbeat for agent.offline
+		if err := u.agentsRepository.Touch(ctx, *agentID, time.Now(), agentSeenInterval); err != nil {
+			log.Printf("[Config] Failed to record poll of agent %s: %v", *agentID, err)
+		}
 	}
 
-	chaced, err := u.cache.GetConfig(ctx)
-	if err == nil && chaced != nil {
-		return chaced, nil
+	chaced, err := u.cache.GetConfig(ctx, namespace)
+	if err == nil && chaced != nil && chaced.Namespace == namespace {
+		if err := chaced.VerifySignature(u.verifier); err == nil {
+			return chaced, nil
+		}
+		log.Printf("[Config] SECURITY: cached config %s (namespace %s, version %d) failed signature verification, reading it from the database", chaced.UUID, namespace, chaced.Version)
 	}
 
-	config, err := u.repository.GetLatestConfig(ctx)
+	config, err := u.repository.GetLatestConfig(ctx, namespace)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return nil, errors.NotFound("config")
@@ -55,17 +91,48 @@ func (u *ConfigUsecase) GetLatestConfig(ctx context.Context, agentID *string) (*
 		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get latest config")
 	}
 
+	// a stored signature is never replaced here: a row that does not verify was
+	// tampered with or signed with a retired key, and must be published again
+	if err := config.VerifySignature(u.verifier); err != nil {
+		log.Printf("[Config] SECURITY: config %s (namespace %s, version %d, key %s) failed signature verification: %v", config.UUID, namespace, config.Version, config.SignatureKeyID, err)
+		return nil, errors.Wrap(err, errors.ErrCodeInvalidSignature, "stored config signature is invalid").
+			WithStatus(http.StatusInternalServerError)
+	}
+
 	u.cache.SetConfig(ctx, config)
 
 	return config, nil
 }
 
-func (u *ConfigUsecase) Create(ctx context.Context, save *config.SaveCreate) (*config.Config, error) {
+func (u *ConfigUsecase) SignUnsignedConfigs(ctx context.Context) error {
+	unsigned, err := u.repository.ListUnsigned(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to list unsigned configs")
+	}
+
+	for i := range unsigned {
+		cfg := &unsigned[i]
+		if err := u.sign(cfg); err != nil {
+			return err
+		}
+		if err := u.repository.Update(ctx, cfg); err != nil {
+			return errors.Wrap(err, errors.ErrCodeInternal, "failed to store config signature")
+		}
+	}
+
+	if len(unsigned) > 0 {
+		log.Printf("[Config] Signed %d config versions stored before signing", len(unsigned))
+	}
+
+	return nil
+}
+
+func (u *ConfigUsecase) Create(ctx context.Context, namespace string, save *config.SaveCreate) (*config.Config, error) {
 	now := time.Now().Format(time.RFC3339)
 
 	var version int
 
-	latestConfig, err := u.repository.GetLatestConfig(ctx)
+	latestConfig, err := u.repository.GetLatestConfig(ctx, namespace)
 	if err != nil && !errors.IsNotFound(err) {
 		return nil, err
 	}
@@ -78,12 +145,17 @@ func (u *ConfigUsecase) Create(ctx context.Context, save *config.SaveCreate) (*c
 
 	newConfig := &config.Config{
 		UUID:      uuid.New().String(),
+		Namespace: namespace,
 		Version:   version,
 		ConfigURL: save.ConfigUrl,
 		PoolingInterval: save.PoolingInterval,
 		CreatedAt: now,
 	}
 
+	if err := u.sign(newConfig); err != nil {
+		return nil, err
+	}
+
 	if err := u.repository.Create(ctx, newConfig); err != nil {
 		return nil, errors.Wrap(err, "config", "failed to create config")
 	}
@@ -92,11 +164,69 @@ func (u *ConfigUsecase) Create(ctx context.Context, save *config.SaveCreate) (*c
 		return nil, errors.Wrap(err, "config", "failed to cache config")
 	}
 
+	u.events.Publish(ctx, webhook.EventConfigPublished, newConfig)
+
+	return newConfig, nil
+}
+
+// Rollback publishes the settings of an older version again. It adds a new
+// version rather than moving back, so agents and workers, which only apply
+// newer versions, pick it up.
+func (u *ConfigUsecase) Rollback(ctx context.Context, namespace string, save *config.SaveRollback) (*config.Config, error) {
+	latestConfig, err := u.repository.GetLatestConfig(ctx, namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.NotFound("config")
+		}
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get latest config")
+	}
+
+	if save.Version >= latestConfig.Version {
+		return nil, errors.Validation(fmt.Sprintf("version %d is not older than the latest version %d", save.Version, latestConfig.Version))
+	}
+
+	restored, err := u.repository.GetByVersion(ctx, namespace, save.Version)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.NotFound("config version")
+		}
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get config version")
+	}
+
+	newConfig := &config.Config{
+		UUID:            uuid.New().String(),
+		Namespace:       namespace,
+		Version:         latestConfig.Version + 1,
+		ConfigURL:       restored.ConfigURL,
+		PoolingInterval: restored.PoolingInterval,
+		CreatedAt:       time.Now().Format(time.RFC3339),
+	}
+
+	if err := u.sign(newConfig); err != nil {
+		return nil, err
+	}
+
+	if err := u.repository.Create(ctx, newConfig); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to create config")
+	}
+
+	if err := u.cache.SetConfig(ctx, newConfig); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to cache config")
+	}
+
+	log.Printf("[Config] Namespace %s rolled back to the settings of version %d as version %d", namespace, restored.Version, newConfig.Version)
+	u.events.Publish(ctx, webhook.EventConfigRolledBack, &config.RolledBack{
+		Namespace:       namespace,
+		FromVersion:     latestConfig.Version,
+		RestoredVersion: restored.Version,
+		Config:          newConfig,
+	})
+
 	return newConfig, nil
 }
 
-func (u *ConfigUsecase) Update(ctx context.Context, save *config.SaveUpdate) error {
-	config, err := u.repository.GetLatestConfig(ctx)
+func (u *ConfigUsecase) Update(ctx context.Context, namespace string, save *config.SaveUpdate) error {
+	config, err := u.repository.GetLatestConfig(ctx, namespace)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return errors.NotFound("config")
@@ -112,6 +242,10 @@ func (u *ConfigUsecase) Update(ctx context.Context, save *config.SaveUpdate) err
 		config.PoolingInterval = *save.PoolingInterval
 	}
 
+	if err := u.sign(config); err != nil {
+		return err
+	}
+
 	if err = u.repository.Update(ctx, config); err != nil {
 		return errors.Wrap(err, "config", "failed to update config")
 	}
@@ -121,5 +255,7 @@ func (u *ConfigUsecase) Update(ctx context.Context, save *config.SaveUpdate) err
 		return errors.Wrap(err, "config", "failed to cache config")
 	}
 
+	u.events.Publish(ctx, webhook.EventConfigPublished, config)
+
 	return nil
 }
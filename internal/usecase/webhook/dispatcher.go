@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"distributed_system/internal/domain/webhook"
+	"distributed_system/pkg/crypto"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	claimBatchSize = 20
+	// maxErrorLength keeps response bodies from bloating the delivery log
+	maxErrorLength = 500
+	userAgent      = "distributed-system-webhooks/1.0"
+)
+
+// Run polls for due deliveries and sends them, waking early when an event is
+// published. Several controllers can run it against the same database.
+func (u *WebhookUsecase) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.cfg.Webhooks.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		u.dispatchDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-u.wake:
+		}
+	}
+}
+
+func (u *WebhookUsecase) dispatchDue(ctx context.Context) {
+	// the lease outlasts a delivery attempt so it is never picked up twice
+	lease := u.cfg.Webhooks.Timeout + time.Minute
+
+	deliveries, err := u.deliveryRepository.ClaimDue(ctx, time.Now(), lease, claimBatchSize)
+	if err != nil {
+		log.Printf("[Webhook] Failed to claim deliveries: %v", err)
+		return
+	}
+
+	hooks := map[string]*webhook.Webhook{}
+	var wg sync.WaitGroup
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		hook, ok := hooks[delivery.WebhookID]
+		if !ok {
+			hook, err = u.repository.GetById(ctx, delivery.WebhookID)
+			if err != nil {
+				log.Printf("[Webhook] Failed to load webhook %s: %v", delivery.WebhookID, err)
+				continue
+			}
+			hooks[delivery.WebhookID] = hook
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u.deliver(ctx, hook, delivery)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// deliver makes one attempt and records the outcome: delivered, scheduled for
+// a retry, or failed for good once the attempts are used up
+func (u *WebhookUsecase) deliver(ctx context.Context, hook *webhook.Webhook, delivery *webhook.Delivery) {
+	now := time.Now()
+	delivery.Attempts++
+
+	var status int
+	var err error
+	if hook.DisabledAt != nil {
+		err = fmt.Errorf("webhook is disabled")
+		delivery.Attempts = u.cfg.Webhooks.MaxAttempts
+	} else {
+		status, err = u.send(ctx, hook, delivery, now)
+	}
+
+	delivery.ResponseStatus = status
+
+	switch {
+	case err == nil:
+		delivery.Status = webhook.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= u.cfg.Webhooks.MaxAttempts:
+		delivery.Status = webhook.DeliveryFailed
+		delivery.LastError = truncate(err.Error())
+		delivery.NextAttemptAt = nil
+		log.Printf("[Webhook] Giving up on %s delivery %s to %s after %d attempts: %v", delivery.EventType, delivery.UUID, hook.URL, delivery.Attempts, err)
+	default:
+		next := now.Add(u.backoff(delivery.Attempts))
+		delivery.LastError = truncate(err.Error())
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := u.deliveryRepository.Update(ctx, delivery); err != nil {
+		log.Printf("[Webhook] Failed to record delivery %s: %v", delivery.UUID, err)
+	}
+}
+
+func (u *WebhookUsecase) send(ctx context.Context, hook *webhook.Webhook, delivery *webhook.Delivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Webhook-ID", hook.UUID)
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	// receivers deduplicate on the delivery id; retries reuse it
+	req.Header.Set("X-Webhook-Delivery", delivery.UUID)
+	req.Header.Set("X-Webhook-Signature", crypto.WebhookSignature(hook.Secret, now, body))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return resp.StatusCode, fmt.Errorf("endpoint answered %d: %s", resp.StatusCode, snippet)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff doubles BackoffBase per failed attempt up to BackoffMax, with up to
+// 20% jitter so receivers coming back up are not hit all at once
+func (u *WebhookUsecase) backoff(attempts int) time.Duration {
+	delay := u.cfg.Webhooks.BackoffBase
+	for i := 1; i < attempts && delay < u.cfg.Webhooks.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > u.cfg.Webhooks.BackoffMax {
+		delay = u.cfg.Webhooks.BackoffMax
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLength {
+		return s[:maxErrorLength]
+	}
+	return s
+}
@@ -0,0 +1,260 @@
+package webhook
+
+import (
+	"context"
+	"distributed_system/internal/config"
+	"distributed_system/internal/domain/webhook"
+	"distributed_system/pkg/crypto"
+	"distributed_system/pkg/errors"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	secretBytes = 32
+	// deliveryLogLimit is how many deliveries GetDeliveries returns
+	deliveryLogLimit = 100
+)
+
+type WebhookUsecase struct {
+	repository         webhook.Repository
+	deliveryRepository webhook.DeliveryRepository
+	cfg                *config.Config
+	client             *http.Client
+	// wake makes the dispatcher send freshly published events without waiting
+	// for the next poll
+	wake chan struct{}
+}
+
+func NewWebhookUsecase(repository webhook.Repository, deliveryRepository webhook.DeliveryRepository, cfg *config.Config) webhook.Usecase {
+	return &WebhookUsecase{
+		repository:         repository,
+		deliveryRepository: deliveryRepository,
+		cfg:                cfg,
+		client: &http.Client{
+			Timeout: cfg.Webhooks.Timeout,
+			// a redirect would send the signed event somewhere nobody configured
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (u *WebhookUsecase) Create(ctx context.Context, actorID string, input *webhook.InputCreateWebhook) (*webhook.CreatedWebhook, error) {
+	random, err := crypto.RandomToken(secretBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to create webhook secret")
+	}
+	secret := webhook.SecretPrefix + random
+
+	hook := webhook.Webhook{
+		UUID:      uuid.New().String(),
+		Name:      strings.TrimSpace(input.Name),
+		URL:       input.URL,
+		Events:    uniqueEvents(input.Events),
+		Secret:    secret,
+		CreatedBy: actorID,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+
+	if err := u.repository.Create(ctx, &hook); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to create webhook")
+	}
+
+	return &webhook.CreatedWebhook{Secret: secret, Webhook: hook}, nil
+}
+
+func (u *WebhookUsecase) GetAll(ctx context.Context) ([]webhook.Webhook, error) {
+	hooks, err := u.repository.GetAll(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get webhooks")
+	}
+
+	return hooks, nil
+}
+
+func (u *WebhookUsecase) GetById(ctx context.Context, ID string) (*webhook.Webhook, error) {
+	hook, err := u.repository.GetById(ctx, ID)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.NotFound("webhook")
+		}
+
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get webhook")
+	}
+
+	return hook, nil
+}
+
+func (u *WebhookUsecase) Update(ctx context.Context, ID string, input *webhook.InputUpdateWebhook) (*webhook.Webhook, error) {
+	hook, err := u.GetById(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if name := strings.TrimSpace(input.Name); name != "" {
+		hook.Name = name
+	}
+	if input.URL != "" {
+		hook.URL = input.URL
+	}
+	if len(input.Events) > 0 {
+		hook.Events = uniqueEvents(input.Events)
+	}
+	if input.Enabled != nil {
+		if *input.Enabled {
+			hook.DisabledAt = nil
+		} else if hook.DisabledAt == nil {
+			now := time.Now()
+			hook.DisabledAt = &now
+		}
+	}
+
+	if err := u.repository.Update(ctx, hook); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to update webhook")
+	}
+
+	return hook, nil
+}
+
+// Delete removes the webhook together with its delivery log
+func (u *WebhookUsecase) Delete(ctx context.Context, ID string) error {
+	if err := u.repository.Delete(ctx, ID); err != nil {
+		if errors.IsNotFound(err) {
+			return errors.NotFound("webhook")
+		}
+
+		return errors.Wrap(err, errors.ErrCodeInternal, "failed to delete webhook")
+	}
+
+	return nil
+}
+
+func (u *WebhookUsecase) GetDeliveries(ctx context.Context, webhookID string) ([]webhook.Delivery, error) {
+	if _, err := u.GetById(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := u.deliveryRepository.GetByWebhook(ctx, webhookID, deliveryLogLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get webhook deliveries")
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues a copy of the delivery so the original attempt stays in the log
+func (u *WebhookUsecase) Redeliver(ctx context.Context, webhookID, deliveryID string) (*webhook.Delivery, error) {
+	delivery, err := u.deliveryRepository.GetById(ctx, deliveryID)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.NotFound("webhook delivery")
+		}
+
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to get webhook delivery")
+	}
+
+	if delivery.WebhookID != webhookID {
+		return nil, errors.NotFound("webhook delivery")
+	}
+
+	return u.enqueue(ctx, webhookID, delivery.EventID, delivery.EventType, []byte(delivery.Payload))
+}
+
+func (u *WebhookUsecase) Ping(ctx context.Context, ID string) (*webhook.Delivery, error) {
+	hook, err := u.GetById(ctx, ID)
+	if err != nil {
+		return nil, err
+	}
+
+	event, payload, err := newEvent(webhook.EventPing, map[string]string{"webhook_id": hook.UUID})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to encode event")
+	}
+
+	return u.enqueue(ctx, hook.UUID, event.ID, event.Type, payload)
+}
+
+func (u *WebhookUsecase) Publish(ctx context.Context, eventType string, data interface{}) {
+	hooks, err := u.repository.GetSubscribed(ctx, eventType)
+	if err != nil {
+		log.Printf("[Webhook] Failed to find webhooks for %s: %v", eventType, err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	event, payload, err := newEvent(eventType, data)
+	if err != nil {
+		log.Printf("[Webhook] Failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if _, err := u.enqueue(ctx, hook.UUID, event.ID, event.Type, payload); err != nil {
+			log.Printf("[Webhook] Failed to queue %s for webhook %s: %v", eventType, hook.UUID, err)
+		}
+	}
+}
+
+func newEvent(eventType string, data interface{}) (*webhook.Event, []byte, error) {
+	event := &webhook.Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return event, payload, nil
+}
+
+func (u *WebhookUsecase) enqueue(ctx context.Context, webhookID, eventID, eventType string, payload []byte) (*webhook.Delivery, error) {
+	now := time.Now()
+
+	delivery := &webhook.Delivery{
+		UUID:          uuid.New().String(),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       string(payload),
+		Status:        webhook.DeliveryPending,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+
+	if err := u.deliveryRepository.Create(ctx, delivery); err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInternal, "failed to queue webhook delivery")
+	}
+
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+
+	return delivery, nil
+}
+
+func uniqueEvents(events []string) []string {
+	seen := map[string]bool{}
+	unique := make([]string, 0, len(events))
+	for _, event := range events {
+		if !seen[event] {
+			seen[event] = true
+			unique = append(unique, event)
+		}
+	}
+	return unique
+}
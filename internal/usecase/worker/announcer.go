@@ -0,0 +1,142 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"distributed_system/internal/domain/worker"
+	"distributed_system/pkg/utils"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Announcer keeps the worker registered with its local agent: it refreshes
+// the announcement every interval, so a restarted agent or one that dropped
+// the worker picks it up again, and withdraws it on shutdown
+type Announcer struct {
+	announcement worker.Announcement
+	agentURL     string
+	token        string
+	// file is the announcement file in the agent's discovery directory
+	file       string
+	interval   time.Duration
+	httpClient *http.Client
+}
+
+// NewAnnouncer announces to agentURL, to a file in dir, or both; either may
+// be empty
+func NewAnnouncer(announcement worker.Announcement, agentURL, token, dir string, interval time.Duration, httpClient *http.Client) *Announcer {
+	a := &Announcer{
+		announcement: announcement,
+		agentURL:     agentURL,
+		token:        token,
+		interval:     interval,
+		httpClient:   httpClient,
+	}
+
+	if dir != "" {
+		sum := sha256.Sum256([]byte(announcement.URL))
+		a.file = filepath.Join(dir, "worker-"+hex.EncodeToString(sum[:8])+".json")
+	}
+
+	return a
+}
+
+// Run announces the worker until ctx is cancelled, then withdraws it
+func (a *Announcer) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.announce(ctx)
+
+		select {
+		case <-ctx.Done():
+			a.withdraw()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Announcer) announce(ctx context.Context) {
+	if a.file != "" {
+		if err := a.writeFile(); err != nil {
+			log.Printf("[Worker] Failed to write announcement %s: %v", a.file, err)
+		}
+	}
+
+	if a.agentURL != "" {
+		body, err := json.Marshal(a.announcement)
+		if err != nil {
+			log.Printf("[Worker] Failed to marshal announcement: %v", err)
+			return
+		}
+
+		if err := a.send(ctx, http.MethodPost, a.agentURL+"/workers", body); err != nil {
+			log.Printf("[Worker] Failed to announce to agent: %v", err)
+		}
+	}
+}
+
+// withdraw tells the agent right away instead of waiting for health checks
+// to fail
+func (a *Announcer) withdraw() {
+	if a.file != "" {
+		if err := os.Remove(a.file); err != nil && !os.IsNotExist(err) {
+			log.Printf("[Worker] Failed to remove announcement %s: %v", a.file, err)
+		}
+	}
+
+	if a.agentURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		target := a.agentURL + "/workers?url=" + url.QueryEscape(a.announcement.URL)
+		if err := a.send(ctx, http.MethodDelete, target, nil); err != nil {
+			log.Printf("[Worker] Failed to withdraw from agent: %v", err)
+		}
+	}
+}
+
+// writeFile replaces the announcement file atomically, so the agent never
+// reads a partial one; rewriting it also tells the agent the worker is alive
+func (a *Announcer) writeFile() error {
+	data, err := json.Marshal(a.announcement)
+	if err != nil {
+		return err
+	}
+
+	// group-readable for an agent running as another user of a shared group
+	return utils.WriteFileAtomic(a.file, data, 0640)
+}
+
+func (a *Announcer) send(ctx context.Context, method, target string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
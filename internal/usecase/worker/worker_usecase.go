@@ -3,8 +3,10 @@ package worker
 import (
 	"context"
 	"distributed_system/internal/domain/worker"
+	"distributed_system/pkg/crypto"
 	"distributed_system/pkg/errors"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -12,25 +14,39 @@ import (
 )
 
 var (
-	globalConfig *worker.WorkerConfig
-	configMutex  sync.RWMutex
+	// globalConfigs holds the applied config of every namespace
+	globalConfigs = map[string]*worker.WorkerConfig{}
+	configMutex   sync.RWMutex
 )
 
 type Worker struct {
 	httpClient *http.Client
+	// allowUnsigned skips verification while no keys are trusted, for
+	// development only
+	allowUnsigned bool
+
+	// verifierMu guards verifier, which starts empty when no keys are
+	// configured and then holds the keys pinned from the first push
+	verifierMu sync.Mutex
+	verifier   *crypto.Ed25519Verifier
 }
 
-func NewWorkerUsecase(httpClient *http.Client) worker.Usecase {
-	return &Worker{httpClient: httpClient}
+// NewWorkerUsecase creates the worker usecase. Every pushed config must carry
+// a valid controller signature from verifier's keys or, when it has none, the
+// keys the agent forwarded with the first push.
+func NewWorkerUsecase(httpClient *http.Client, verifier *crypto.Ed25519Verifier, allowUnsigned bool) worker.Usecase {
+	return &Worker{httpClient: httpClient, verifier: verifier, allowUnsigned: allowUnsigned}
 }
 
-func (u *Worker) Hit(ctx context.Context) (any, error) {
-	configMutex.Lock()
+func (u *Worker) Hit(ctx context.Context, namespace string) (any, error) {
+	configMutex.RLock()
+	globalConfig := globalConfigs[namespace]
 	if globalConfig == nil {
+		configMutex.RUnlock()
 		return nil, errors.NotFound("config")
 	}
 	configURL := globalConfig.ConfigURL
-	configMutex.Unlock()
+	configMutex.RUnlock()
 
 	if configURL == "" {
 		return nil, errors.NotFound("config")
@@ -68,18 +84,42 @@ func (u *Worker) Hit(ctx context.Context) (any, error) {
 }
 
 func (u *Worker) UpdateConfig(ctx context.Context, req worker.UpdateConfigRequest) error {
+	verifier, err := u.configVerifier(req.ConfigSigningKeys)
+	if err != nil {
+		return err
+	}
+	if verifier != nil {
+		if err := req.Config().VerifySignature(verifier); err != nil {
+			log.Printf("[Worker] Rejected config version %d: %v", req.Version, err)
+			return errors.Wrap(err, errors.ErrCodeInvalidSignature, "config signature verification failed").
+				WithStatus(http.StatusUnprocessableEntity)
+		}
+	}
+
 	configMutex.Lock()
 	defer configMutex.Unlock()
 
-	globalConfig = &worker.WorkerConfig{
+	// an older config is validly signed too, so replaying it must not
+	// downgrade the worker
+	if current := globalConfigs[req.Namespace]; current != nil && req.Version < current.Version {
+		log.Printf("[Worker] Rejected config version %d of namespace %s, version %d is applied", req.Version, req.Namespace, current.Version)
+		return errors.New(errors.ErrCodeInvalidStatus, fmt.Sprintf("config version %d is older than the applied version %d", req.Version, current.Version)).
+			WithStatus(http.StatusConflict)
+	}
+
+	globalConfig := &worker.WorkerConfig{
 		ConfigURL:       req.ConfigURL,
 		PoolingInterval: req.PoolingInterval,
 		Version:         req.Version,
 		UUID:            req.UUID,
+		Signature:       req.Signature,
+		SignatureKeyID:  req.SignatureKeyID,
 	}
+	globalConfigs[req.Namespace] = globalConfig
 
 	log.Printf("============================================================")
 	log.Println("[Worker] CONFIG UPDATED FROM AGENT!")
+	log.Printf("  Namespace: %s", req.Namespace)
 	log.Printf("  UUID: %s", globalConfig.UUID)
 	log.Printf("  Version: %d", globalConfig.Version)
 	log.Printf("  Config URL: %s", globalConfig.ConfigURL)
@@ -87,4 +127,48 @@ func (u *Worker) UpdateConfig(ctx context.Context, req worker.UpdateConfigReques
 	log.Printf("============================================================")
 
 	return nil
-}
\ No newline at end of file
+}
+
+// configVerifier returns the verifier for a pushed config, pinning the keys
+// the agent forwarded when none are trusted yet. It is nil only when unsigned
+// configs are allowed.
+func (u *Worker) configVerifier(forwarded []string) (*crypto.Ed25519Verifier, error) {
+	u.verifierMu.Lock()
+	defer u.verifierMu.Unlock()
+
+	if u.verifier.Enabled() {
+		return u.verifier, nil
+	}
+	if u.allowUnsigned {
+		return nil, nil
+	}
+
+	if len(forwarded) == 0 {
+		log.Println("[Worker] Rejected config: no trusted config signing keys and none forwarded by the agent")
+		return nil, errors.New(errors.ErrCodeInvalidSignature, "no trusted config signing keys").
+			WithStatus(http.StatusUnprocessableEntity)
+	}
+
+	verifier, err := crypto.NewEd25519Verifier(forwarded)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCodeInvalidSignature, "invalid config signing keys").
+			WithStatus(http.StatusUnprocessableEntity)
+	}
+
+	u.verifier = verifier
+	log.Printf("[Worker] Pinned %d config signing key(s) forwarded by the agent", len(forwarded))
+	return verifier, nil
+}
+
+// AppliedVersion is the version of the namespace's config in use, 0 before
+// the first push
+func (u *Worker) AppliedVersion(ctx context.Context, namespace string) int {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	globalConfig := globalConfigs[namespace]
+	if globalConfig == nil {
+		return 0
+	}
+	return globalConfig.Version
+}
@@ -0,0 +1,11 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_agents_hostname;
+-- Drop columns
+ALTER TABLE agents
+    DROP COLUMN IF EXISTS hostname,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS os,
+    DROP COLUMN IF EXISTS arch,
+    DROP COLUMN IF EXISTS agent_version,
+    DROP COLUMN IF EXISTS worker_urls,
+    DROP COLUMN IF EXISTS labels;
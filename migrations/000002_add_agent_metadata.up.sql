@@ -0,0 +1,11 @@
+ALTER TABLE agents
+    ADD COLUMN IF NOT EXISTS hostname TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ip_address TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS os TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS arch TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS agent_version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS worker_urls JSONB NOT NULL DEFAULT '[]',
+    ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_agents_hostname
+ON agents(hostname);
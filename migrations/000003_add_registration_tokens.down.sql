@@ -0,0 +1,8 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_registration_tokens_expires_at;
+-- Drop columns
+ALTER TABLE agents
+    DROP COLUMN IF EXISTS environment,
+    DROP COLUMN IF EXISTS registration_token_id;
+-- Drop tables
+DROP TABLE IF EXISTS registration_tokens;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS registration_tokens (
+    uuid TEXT PRIMARY KEY NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    environment TEXT NOT NULL DEFAULT '',
+    labels JSONB NOT NULL DEFAULT '{}',
+    max_uses BIGINT NOT NULL DEFAULT 1,
+    used_count BIGINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE agents
+    ADD COLUMN IF NOT EXISTS environment TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS registration_token_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_registration_tokens_expires_at
+ON registration_tokens(expires_at);
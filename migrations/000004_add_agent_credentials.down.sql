@@ -0,0 +1,5 @@
+-- Drop columns
+ALTER TABLE agents
+    DROP COLUMN IF EXISTS credential_generation,
+    DROP COLUMN IF EXISTS credential_expires_at,
+    DROP COLUMN IF EXISTS credential_revoked_at;
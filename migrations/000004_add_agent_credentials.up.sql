@@ -0,0 +1,6 @@
+-- Existing agents keep generation 0 so their legacy credentials stay valid
+-- until they rotate; new agents start at generation 1.
+ALTER TABLE agents
+    ADD COLUMN IF NOT EXISTS credential_generation BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS credential_expires_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS credential_revoked_at TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+-- Drop columns
+ALTER TABLE agents
+    DROP COLUMN IF EXISTS namespaces;
+ALTER TABLE registration_tokens
+    DROP COLUMN IF EXISTS namespaces;
@@ -0,0 +1,5 @@
+ALTER TABLE agents
+    ADD COLUMN IF NOT EXISTS namespaces JSONB NOT NULL DEFAULT '["default"]';
+
+ALTER TABLE registration_tokens
+    ADD COLUMN IF NOT EXISTS namespaces JSONB NOT NULL DEFAULT '["default"]';
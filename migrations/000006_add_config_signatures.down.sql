@@ -0,0 +1,4 @@
+-- Drop columns
+ALTER TABLE config
+    DROP COLUMN IF EXISTS signature,
+    DROP COLUMN IF EXISTS signature_key_id;
@@ -0,0 +1,3 @@
+ALTER TABLE config
+    ADD COLUMN IF NOT EXISTS signature TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS signature_key_id TEXT NOT NULL DEFAULT '';
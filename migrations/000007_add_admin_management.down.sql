@@ -0,0 +1,9 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_admin_email;
+CREATE INDEX IF NOT EXISTS idx_admin_email
+ON admin(email);
+-- Drop columns
+ALTER TABLE admin
+    DROP COLUMN IF EXISTS must_change_password,
+    DROP COLUMN IF EXISTS password_changed_at,
+    DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,14 @@
+ALTER TABLE admin
+    ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
+
+-- the seeded account still uses the published default password
+UPDATE admin
+SET must_change_password = TRUE
+WHERE email = 'admin@distributed-system.com' AND password_changed_at IS NULL;
+
+DROP INDEX IF EXISTS idx_admin_email;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_admin_email
+ON admin(email);
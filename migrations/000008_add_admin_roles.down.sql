@@ -0,0 +1,3 @@
+-- Drop columns
+ALTER TABLE admin
+    DROP COLUMN IF EXISTS role;
@@ -0,0 +1,7 @@
+-- every existing admin had full access, so they start as owners
+ALTER TABLE admin
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'owner'
+    CHECK (role IN ('viewer', 'editor', 'approver', 'owner'));
+
+ALTER TABLE admin
+    ALTER COLUMN role SET DEFAULT 'viewer';
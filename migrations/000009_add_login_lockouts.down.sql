@@ -0,0 +1,4 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_login_lockouts_created_at;
+-- Drop tables
+DROP TABLE IF EXISTS login_lockouts;
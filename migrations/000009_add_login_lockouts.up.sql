@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    uuid TEXT PRIMARY KEY NOT NULL,
+    scope TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    attempts BIGINT NOT NULL,
+    locked_until TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_lockouts_created_at
+ON login_lockouts(created_at);
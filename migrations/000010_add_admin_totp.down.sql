@@ -0,0 +1,6 @@
+-- Drop columns
+ALTER TABLE admin
+    DROP COLUMN IF EXISTS totp_secret,
+    DROP COLUMN IF EXISTS totp_enabled_at,
+    DROP COLUMN IF EXISTS totp_last_step,
+    DROP COLUMN IF EXISTS totp_recovery_codes;
@@ -0,0 +1,4 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_api_keys_created_by;
+-- Drop tables
+DROP TABLE IF EXISTS api_keys;
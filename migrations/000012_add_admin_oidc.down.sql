@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_admin_oidc_identity;
+-- Drop columns
+ALTER TABLE admin
+    DROP COLUMN IF EXISTS oidc_issuer,
+    DROP COLUMN IF EXISTS oidc_subject;
@@ -0,0 +1,7 @@
+ALTER TABLE admin
+    ADD COLUMN IF NOT EXISTS oidc_issuer TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS oidc_subject TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_admin_oidc_identity
+ON admin(oidc_issuer, oidc_subject)
+WHERE oidc_subject <> '';
@@ -0,0 +1,10 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_id;
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+-- Drop columns
+ALTER TABLE agents
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS offline_at;
+-- Drop tables
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,11 @@
+-- Drop columns
+DROP INDEX IF EXISTS idx_config_namespace_version;
+
+DELETE FROM config
+    WHERE namespace <> 'default';
+
+ALTER TABLE config
+    DROP COLUMN IF EXISTS namespace;
+
+ALTER TABLE config
+    ADD CONSTRAINT config_version_key UNIQUE (version);
@@ -0,0 +1,9 @@
+ALTER TABLE config
+    ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT 'default';
+
+-- versions are counted per namespace
+ALTER TABLE config
+    DROP CONSTRAINT IF EXISTS config_version_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_config_namespace_version
+ON config(namespace, version);
@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// SealerKeySize is the key length of a Sealer (AES-256)
+const SealerKeySize = 32
+
+// ErrDecrypt is returned when sealed data was tampered with or sealed with
+// another key
+var ErrDecrypt = errors.New("failed to decrypt: wrong key or corrupted data")
+
+// Sealer encrypts and authenticates data with AES-256-GCM. Sealed data is the
+// random nonce followed by the ciphertext.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+func NewSealer(key []byte) (*Sealer, error) {
+	if len(key) != SealerKeySize {
+		return nil, fmt.Errorf("sealer key must be %d bytes", SealerKeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{aead: aead}, nil
+}
+
+// Seal encrypts plaintext. additionalData is authenticated but not stored, so
+// Open must be given the same value; use it to bind data to its context.
+func (s *Sealer) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (s *Sealer) Open(sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// KeyFromPassphrase stretches a passphrase into a sealer key with Argon2id
+func KeyFromPassphrase(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, 3, 64*1024, 4, SealerKeySize)
+}
+
+// KeyFromSecret derives a sealer key from a high-entropy secret, such as a
+// random key file, with HKDF-SHA256
+func KeyFromSecret(secret, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, SealerKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
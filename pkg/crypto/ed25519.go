@@ -0,0 +1,176 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrInvalidSignature is returned when a payload does not match its signature
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Ed25519Signer signs payloads with a private key identified by the id of its
+// public key
+type Ed25519Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// LoadOrCreateEd25519Signer reads a PKCS#8 PEM private key, generating and
+// persisting a new one when the file does not exist yet
+func LoadOrCreateEd25519Signer(keyFile string) (*Ed25519Signer, error) {
+	keyPEM, err := os.ReadFile(keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return createEd25519Signer(keyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("invalid signing key PEM")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an Ed25519 key")
+	}
+
+	return NewEd25519Signer(key), nil
+}
+
+func createEd25519Signer(keyFile string) (*Ed25519Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+
+	return NewEd25519Signer(key), nil
+}
+
+func NewEd25519Signer(key ed25519.PrivateKey) *Ed25519Signer {
+	pub := key.Public().(ed25519.PublicKey)
+	return &Ed25519Signer{keyID: PublicKeyID(pub), key: key}
+}
+
+// KeyID identifies the public key verifiers need to check signatures
+func (s *Ed25519Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey returns the base64 public key to distribute to verifiers
+func (s *Ed25519Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign returns the base64 signature of payload
+func (s *Ed25519Signer) Sign(payload []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, payload))
+}
+
+// Ed25519Verifier checks signatures against a set of trusted public keys so
+// a new signing key can be rolled out before the old one is retired
+type Ed25519Verifier struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// NewEd25519Verifier builds a verifier from base64 encoded public keys
+func NewEd25519Verifier(publicKeys []string) (*Ed25519Verifier, error) {
+	v := &Ed25519Verifier{keys: make(map[string]ed25519.PublicKey, len(publicKeys))}
+
+	for _, encoded := range publicKeys {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key %q: %w", encoded, err)
+		}
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key %q: wrong length", encoded)
+		}
+		pub := ed25519.PublicKey(raw)
+		v.keys[PublicKeyID(pub)] = pub
+	}
+
+	return v, nil
+}
+
+// Enabled is false when no trusted keys are configured
+func (v *Ed25519Verifier) Enabled() bool {
+	return v != nil && len(v.keys) > 0
+}
+
+// Verify checks a base64 signature made by the key with keyID
+func (v *Ed25519Verifier) Verify(payload []byte, keyID, signature string) error {
+	pub, ok := v.keys[keyID]
+	if !ok {
+		return fmt.Errorf("untrusted signing key %q", keyID)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(pub, payload, sig) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// PublicKeyID is the first 8 bytes of the SHA-256 of the public key, hex encoded
+func PublicKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// CanonicalJSON encodes v as compact JSON with object keys sorted, so signer
+// and verifier produce identical bytes regardless of struct field order
+func CanonicalJSON(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	// encoding/json writes map keys in sorted order
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(generic); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
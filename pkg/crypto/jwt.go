@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SignJWT signs claims as an HS256 JWT with the active key and sets the kid
+// header unless the legacy key is active
+func (k *Keyring) SignJWT(claims jwt.Claims) (string, error) {
+	kid, secret, ok := k.Active()
+	if !ok {
+		return "", errors.New("no active signing key configured")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if kid != LegacyKeyID {
+		token.Header["kid"] = kid
+	}
+
+	return token.SignedString(secret)
+}
+
+// ParseJWT verifies an HMAC-signed JWT against the key named by its kid header
+// (the legacy key when there is none) and decodes it into claims
+func (k *Keyring) ParseJWT(token string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}))
+
+	return jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid := LegacyKeyID
+		if v, ok := t.Header["kid"]; ok {
+			s, ok := v.(string)
+			if !ok {
+				return nil, jwt.ErrTokenUnverifiable
+			}
+			kid = s
+		}
+
+		secret, ok := k.Get(kid)
+		if !ok {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+
+		return secret, nil
+	}, opts...)
+}
@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LegacyKeyID identifies the single pre-keyring secret. Tokens signed with it
+// carry no kid.
+const LegacyKeyID = ""
+
+// Keyring holds every signing key that is still accepted for verification and
+// marks one of them as active for signing new tokens
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// NewKeyring builds a keyring from kid -> secret pairs. The active kid must be
+// one of the keys; an empty keyring is allowed but cannot sign or verify.
+func NewKeyring(active string, keys map[string]string) (*Keyring, error) {
+	k := &Keyring{
+		active: active,
+		keys:   make(map[string][]byte, len(keys)),
+	}
+
+	for kid, secret := range keys {
+		if strings.ContainsAny(kid, ". ") {
+			return nil, fmt.Errorf("key id %q must not contain dots or spaces", kid)
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("key %q has an empty secret", kid)
+		}
+		k.keys[kid] = []byte(secret)
+	}
+
+	if _, ok := k.keys[active]; !ok && len(k.keys) > 0 {
+		return nil, fmt.Errorf("active key %q is not in the keyring", active)
+	}
+
+	return k, nil
+}
+
+// Active returns the kid and secret used to sign new tokens, or false when the
+// keyring is empty
+func (k *Keyring) Active() (string, []byte, bool) {
+	secret, ok := k.keys[k.active]
+	return k.active, secret, ok
+}
+
+// Get returns the secret for a kid
+func (k *Keyring) Get(kid string) ([]byte, bool) {
+	secret, ok := k.keys[kid]
+	return secret, ok
+}
+
+// Sign signs text with the active key. Tokens signed with a named key are
+// prefixed with its kid: kid.base64(text).base64(hmac).
+func (k *Keyring) Sign(text string) (string, error) {
+	kid, secret, ok := k.Active()
+	if !ok {
+		return "", errors.New("no active signing key configured")
+	}
+
+	signed, err := Generate(text, string(secret))
+	if err != nil {
+		return "", err
+	}
+
+	if kid == LegacyKeyID {
+		return signed, nil
+	}
+
+	return kid + "." + signed, nil
+}
+
+// Verify checks a token produced by Sign (or by Generate with the legacy
+// secret) and returns the signed text
+func (k *Keyring) Verify(signedText string) (bool, string, error) {
+	kid := LegacyKeyID
+	token := signedText
+
+	if parts := strings.SplitN(signedText, ".", 3); len(parts) == 3 {
+		kid = parts[0]
+		token = parts[1] + "." + parts[2]
+	}
+
+	secret, ok := k.Get(kid)
+	if !ok {
+		return false, "", errors.New("unknown signing key")
+	}
+
+	return Verify(token, string(secret))
+}
@@ -142,6 +142,7 @@ const (
 	ErrCodeInvalidToken     = "ERR_INVALID_TOKEN"
 	ErrCodeTokenExpired     = "ERR_TOKEN_EXPIRED"
 	ErrCodeInvalidCredential = "ERR_INVALID_CREDENTIAL"
+	ErrCodeInvalidSignature  = "ERR_INVALID_SIGNATURE"
 
 	// Validation
 	ErrCodeValidation      = "ERR_VALIDATION"
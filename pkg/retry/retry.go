@@ -0,0 +1,97 @@
+// Package retry retries transient failures with exponential backoff and
+// jitter. Whether an error is transient is decided by errors.IsRetryable.
+package retry
+
+import (
+	"context"
+	"distributed_system/pkg/errors"
+	"math/rand"
+	"time"
+)
+
+// Policy describes how an operation is retried. The zero value makes a
+// single attempt.
+type Policy struct {
+	// InitialInterval is the wait after the first failure
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+	// MaxInterval caps the wait between attempts
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+	// Multiplier grows the wait after every failure
+	Multiplier float64 `mapstructure:"multiplier"`
+	// Jitter randomizes each wait by up to this fraction (0.2 = ±20%) so
+	// agents that failed together do not retry together
+	Jitter float64 `mapstructure:"jitter"`
+	// MaxElapsed stops retrying once this much time has passed since the
+	// first attempt; 0 retries until the context is done
+	MaxElapsed time.Duration `mapstructure:"max_elapsed"`
+}
+
+// Do runs op until it succeeds, fails with an error that is not retryable,
+// MaxElapsed is used up or ctx is done, and returns the last error. notify,
+// when set, is called before every wait.
+func (p Policy) Do(ctx context.Context, op func(ctx context.Context) error, notify func(err error, wait time.Duration)) error {
+	if p.InitialInterval <= 0 {
+		return op(ctx)
+	}
+
+	start := time.Now()
+	interval := p.InitialInterval
+
+	for {
+		err := op(ctx)
+		if err == nil || !errors.IsRetryable(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		wait := p.jitter(interval)
+		if p.MaxElapsed > 0 && time.Since(start)+wait > p.MaxElapsed {
+			return err
+		}
+
+		if notify != nil {
+			notify(err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		interval = p.next(interval)
+	}
+}
+
+// WithoutLimit returns the policy with MaxElapsed removed, for operations
+// that must eventually succeed
+func (p Policy) WithoutLimit() Policy {
+	p.MaxElapsed = 0
+	return p
+}
+
+func (p Policy) next(interval time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	next := time.Duration(float64(interval) * multiplier)
+	if p.MaxInterval > 0 && next > p.MaxInterval {
+		next = p.MaxInterval
+	}
+	return next
+}
+
+func (p Policy) jitter(interval time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return interval
+	}
+
+	delta := p.Jitter * float64(interval)
+	return interval + time.Duration(delta*(2*rand.Float64()-1))
+}
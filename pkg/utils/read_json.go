@@ -9,7 +9,7 @@ import (
 )
 
 func ReadJSON[T any](fileName string) (*T, error) {
-    file, err := os.ReadFile(filepath.Join(".", fileName))
+    file, err := os.ReadFile(filepath.Join(".", fmt.Sprintf("%s.json", fileName)))
     if err != nil {
         return nil, err
     }
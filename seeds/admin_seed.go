@@ -68,6 +68,10 @@ func (s *AdminSeed) SeedAdmin(data *AdminSeedData) error {
 		UUID:      adminUUID,
 		Email:     data.Email,
 		Password:  string(hashedPassword),
+		Role:      admin.RoleOwner,
+		// password default wajib diganti saat login pertama
+		MustChangePassword: true,
+		TOTPRecoveryCodes: []string{},
 		CreatedAt: now,
 	}
 
@@ -80,7 +84,7 @@ func (s *AdminSeed) SeedAdmin(data *AdminSeedData) error {
 	log.Printf("   UUID: %s\n", adminUUID)
 	log.Printf("   Email: %s\n", data.Email)
 	log.Printf("   Password: %s\n", data.Password)
-	log.Printf("   ⚠️  Harap ubah password setelah login pertama!\n")
+	log.Printf("   ⚠️  Password wajib diubah saat login pertama (PUT /admin/me/password)\n")
 
 	return nil
 }